@@ -1,63 +1,319 @@
 package db
 
 import (
+	"context"
 	"database/sql"
+	"embed"
 	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
 )
 
+//go:embed migrations/postgres/*.sql migrations/sqlite/*.sql
+var migrationFiles embed.FS
+
+// migrationLockKey — ключ pg_advisory_lock, под которым выполняются миграции,
+// чтобы несколько экземпляров приложения не мигрировали базу одновременно.
+const migrationLockKey int64 = 0x70725f6d6967 // "pr_mig"
+
 /*
-Migrate выполняет миграцию схемы базы данных PostgreSQL.
+Dialect описывает особенности СУБД для мигратора: каталог миграций,
+DDL таблицы schema_migrations, плейсхолдеры и межпроцессную блокировку.
+*/
+type Dialect struct {
+	dir           string
+	createTable   string
+	insertVersion string
+	deleteVersion string
+	lock          func(ctx context.Context, conn *sql.Conn) (unlock func(), err error)
+}
 
-Этот метод вызывается один раз при старте приложения и гарантирует,
-что все необходимые таблицы и типы существуют
+// Postgres — миграции PostgreSQL под pg_advisory_lock.
+var Postgres = Dialect{
+	dir: "migrations/postgres",
+	createTable: `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    BIGINT PRIMARY KEY,
+			name       TEXT NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		)`,
+	insertVersion: `INSERT INTO schema_migrations(version, name) VALUES ($1, $2)`,
+	deleteVersion: `DELETE FROM schema_migrations WHERE version=$1`,
+	lock: func(ctx context.Context, conn *sql.Conn) (func(), error) {
+		if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLockKey); err != nil {
+			return nil, err
+		}
+		return func() {
+			_, _ = conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationLockKey)
+		}, nil
+	},
+}
+
+/*
+SQLite — миграции SQLite. Отдельной блокировки нет: база принадлежит
+одному процессу, а каждая миграция выполняется в своей транзакции.
+*/
+var SQLite = Dialect{
+	dir: "migrations/sqlite",
+	createTable: `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    INTEGER PRIMARY KEY,
+			name       TEXT NOT NULL,
+			applied_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now'))
+		)`,
+	insertVersion: `INSERT INTO schema_migrations(version, name) VALUES (?, ?)`,
+	deleteVersion: `DELETE FROM schema_migrations WHERE version=?`,
+	lock: func(context.Context, *sql.Conn) (func(), error) {
+		return func() {}, nil
+	},
+}
+
+// Migration — одна версия схемы с SQL для применения и отката.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+var migrationFileRe = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+/*
+loadMigrations читает пары файлов NNNN_name.up.sql / NNNN_name.down.sql
+из каталога dir и возвращает миграции по возрастанию версии.
+*/
+func loadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, e := range entries {
+		m := migrationFileRe.FindStringSubmatch(e.Name())
+		if m == nil {
+			continue
+		}
+
+		version, err := strconv.ParseInt(m[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: %w", e.Name(), err)
+		}
+
+		body, err := fs.ReadFile(fsys, path.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		}
+		if mig.Name != m[2] {
+			return nil, fmt.Errorf("migration %d has conflicting names %q and %q", version, mig.Name, m[2])
+		}
+
+		if m[3] == "up" {
+			mig.Up = string(body)
+		} else {
+			mig.Down = string(body)
+		}
+	}
+
+	result := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" || mig.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s must have both up and down files", mig.Version, mig.Name)
+		}
+		result = append(result, *mig)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Version < result[j].Version })
+
+	return result, nil
+}
+
+/*
+Migrator применяет и откатывает версионированные миграции схемы.
+Применённые версии хранятся в таблице schema_migrations.
+*/
+type Migrator struct {
+	db         *sql.DB
+	dialect    Dialect
+	migrations []Migration
+}
+
+func NewMigrator(db *sql.DB, d Dialect) (*Migrator, error) {
+	migrations, err := loadMigrations(migrationFiles, d.dir)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, dialect: d, migrations: migrations}, nil
+}
+
+// Latest возвращает номер последней известной миграции.
+func (m *Migrator) Latest() int64 {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Up применяет все ещё не применённые миграции.
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.To(ctx, m.Latest())
+}
+
+/*
+To приводит схему к версии target: применяет недостающие миграции
+с версией не выше target и откатывает применённые миграции выше неё.
+target = 0 откатывает все миграции.
 */
-func Migrate(db *sql.DB) error {
-	statements := []string{
-		// Таблица команд.
-		`CREATE TABLE IF NOT EXISTS teams (
-			name TEXT PRIMARY KEY
-		);`,
-
-		// Таблица пользователей.
-		`CREATE TABLE IF NOT EXISTS users (
-			user_id   TEXT PRIMARY KEY,
-			username  TEXT NOT NULL,
-			team_name TEXT NOT NULL REFERENCES teams(name) ON DELETE RESTRICT,
-			is_active BOOLEAN NOT NULL DEFAULT TRUE
-		);`,
-
-		// Создание enum-типа pr_status: OPEN | MERGED.
-		`DO $$
-		BEGIN
-			IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'pr_status') THEN
-				CREATE TYPE pr_status AS ENUM ('OPEN', 'MERGED');
-			END IF;
-		END$$;`,
-
-		// Таблица Pull Requests.
-		`CREATE TABLE IF NOT EXISTS pull_requests (
-			pull_request_id   TEXT PRIMARY KEY,
-			pull_request_name TEXT NOT NULL,
-			author_id         TEXT NOT NULL REFERENCES users(user_id),
-			status            pr_status NOT NULL DEFAULT 'OPEN',
-			created_at        TIMESTAMPTZ NOT NULL DEFAULT now(),
-			merged_at         TIMESTAMPTZ
-		);`,
-
-		// Таблица связи PR и ревьюверов (many-to-many).
-		`CREATE TABLE IF NOT EXISTS pull_request_reviewers (
-			pull_request_id TEXT NOT NULL REFERENCES pull_requests(pull_request_id) ON DELETE CASCADE,
-			user_id         TEXT NOT NULL REFERENCES users(user_id),
-			PRIMARY KEY (pull_request_id, user_id)
-		);`,
-	}
-
-	for i, stmt := range statements {
-		_, err := db.Exec(stmt)
+func (m *Migrator) To(ctx context.Context, target int64) error {
+	if target != 0 && m.find(target) == nil {
+		return fmt.Errorf("unknown migration version %d", target)
+	}
+
+	return m.locked(ctx, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
 		if err != nil {
-			return fmt.Errorf("migration %d failed: %w", i, err)
+			return err
 		}
+
+		for _, mig := range m.migrations {
+			if mig.Version > target || applied[mig.Version] {
+				continue
+			}
+			if err := apply(ctx, conn, mig.Up, m.dialect.insertVersion, mig.Version, mig.Name); err != nil {
+				return fmt.Errorf("migration %d_%s up failed: %w", mig.Version, mig.Name, err)
+			}
+		}
+
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			mig := m.migrations[i]
+			if mig.Version <= target || !applied[mig.Version] {
+				continue
+			}
+			if err := apply(ctx, conn, mig.Down, m.dialect.deleteVersion, mig.Version); err != nil {
+				return fmt.Errorf("migration %d_%s down failed: %w", mig.Version, mig.Name, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// Down откатывает последнюю применённую миграцию.
+func (m *Migrator) Down(ctx context.Context) error {
+	current, err := m.Version(ctx)
+	if err != nil {
+		return err
+	}
+	if current == 0 {
+		return nil
 	}
 
+	var prev int64
+	for _, mig := range m.migrations {
+		if mig.Version < current {
+			prev = mig.Version
+		}
+	}
+	return m.To(ctx, prev)
+}
+
+// Version возвращает номер последней применённой миграции (0 — схема пуста).
+func (m *Migrator) Version(ctx context.Context) (int64, error) {
+	var version int64
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		return conn.QueryRowContext(ctx,
+			`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`,
+		).Scan(&version)
+	})
+	return version, err
+}
+
+func (m *Migrator) find(version int64) *Migration {
+	for i := range m.migrations {
+		if m.migrations[i].Version == version {
+			return &m.migrations[i]
+		}
+	}
 	return nil
 }
+
+/*
+locked выполняет fn на отдельном соединении под блокировкой диалекта.
+pg_advisory_lock сессионный, поэтому все запросы идут через одно соединение.
+*/
+func (m *Migrator) locked(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = conn.Close() }()
+
+	unlock, err := m.dialect.lock(ctx, conn)
+	if err != nil {
+		return fmt.Errorf("acquire migration lock: %w", err)
+	}
+	defer unlock()
+
+	if _, err := conn.ExecContext(ctx, m.dialect.createTable); err != nil {
+		return err
+	}
+
+	return fn(conn)
+}
+
+func appliedVersions(ctx context.Context, conn *sql.Conn) (map[int64]bool, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	applied := map[int64]bool{}
+	for rows.Next() {
+		var v int64
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		applied[v] = true
+	}
+	return applied, rows.Err()
+}
+
+// apply выполняет SQL миграции и запись в schema_migrations в одной транзакции.
+func apply(ctx context.Context, conn *sql.Conn, body, record string, args ...interface{}) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, body); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+/*
+Migrate применяет все миграции схемы базы данных указанного диалекта.
+
+Этот метод вызывается один раз при старте приложения и гарантирует,
+что схема доведена до последней версии.
+*/
+func Migrate(db *sql.DB, d Dialect) error {
+	m, err := NewMigrator(db, d)
+	if err != nil {
+		return err
+	}
+	return m.Up(context.Background())
+}
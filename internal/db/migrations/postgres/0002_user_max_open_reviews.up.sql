@@ -0,0 +1,4 @@
+-- Лимит открытых ревью на пользователя (0 — без ограничения).
+ALTER TABLE users
+	ADD COLUMN IF NOT EXISTS max_open_reviews INTEGER NOT NULL DEFAULT 0
+	CHECK (max_open_reviews >= 0);
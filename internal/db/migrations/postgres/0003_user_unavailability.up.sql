@@ -0,0 +1,12 @@
+-- Окна недоступности пользователей (отпуск, больничный и т.п.).
+CREATE TABLE IF NOT EXISTS user_unavailability (
+	window_id BIGSERIAL PRIMARY KEY,
+	user_id   TEXT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+	starts_at TIMESTAMPTZ NOT NULL,
+	ends_at   TIMESTAMPTZ NOT NULL,
+	reason    TEXT NOT NULL DEFAULT '',
+	CHECK (ends_at > starts_at)
+);
+
+CREATE INDEX IF NOT EXISTS user_unavailability_user_idx
+	ON user_unavailability (user_id, ends_at);
@@ -0,0 +1,3 @@
+-- Переназначать ли открытые ревью участника при его деактивации.
+ALTER TABLE teams
+	ADD COLUMN IF NOT EXISTS auto_reassign_on_deactivate BOOLEAN NOT NULL DEFAULT FALSE;
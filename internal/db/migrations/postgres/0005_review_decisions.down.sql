@@ -0,0 +1,6 @@
+ALTER TABLE pull_request_reviewers
+	DROP COLUMN IF EXISTS submitted_at,
+	DROP COLUMN IF EXISTS assigned_at,
+	DROP COLUMN IF EXISTS state;
+
+DROP TYPE IF EXISTS review_state;
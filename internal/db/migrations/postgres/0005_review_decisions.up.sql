@@ -0,0 +1,13 @@
+-- Решение ревьювера по PR: PENDING, пока ревью не отправлено.
+CREATE TYPE review_state AS ENUM ('PENDING', 'APPROVED', 'CHANGES_REQUESTED', 'COMMENTED');
+
+ALTER TABLE pull_request_reviewers
+	ADD COLUMN state        review_state NOT NULL DEFAULT 'PENDING',
+	ADD COLUMN assigned_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+	ADD COLUMN submitted_at TIMESTAMPTZ;
+
+-- Для уже существующих назначений точное время неизвестно — берём создание PR.
+UPDATE pull_request_reviewers r
+SET assigned_at = pr.created_at
+FROM pull_requests pr
+WHERE pr.pull_request_id = r.pull_request_id;
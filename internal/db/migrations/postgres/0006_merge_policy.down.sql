@@ -0,0 +1,5 @@
+ALTER TABLE pull_requests DROP COLUMN IF EXISTS merge_override;
+
+ALTER TABLE teams
+	DROP COLUMN IF EXISTS block_on_changes_requested,
+	DROP COLUMN IF EXISTS min_approvals;
@@ -0,0 +1,8 @@
+-- Политика мерджа команды автора PR.
+ALTER TABLE teams
+	ADD COLUMN IF NOT EXISTS min_approvals INT NOT NULL DEFAULT 0 CHECK (min_approvals >= 0),
+	ADD COLUMN IF NOT EXISTS block_on_changes_requested BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- PR замёрджен в обход политики.
+ALTER TABLE pull_requests
+	ADD COLUMN IF NOT EXISTS merge_override BOOLEAN NOT NULL DEFAULT FALSE;
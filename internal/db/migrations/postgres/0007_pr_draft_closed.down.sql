@@ -0,0 +1,15 @@
+-- Значения enum нельзя удалить, поэтому тип пересоздаётся.
+-- DRAFT и CLOSED PR возвращаются в OPEN.
+ALTER TABLE pull_requests DROP COLUMN IF EXISTS closed_at;
+
+UPDATE pull_requests SET status = 'OPEN' WHERE status IN ('DRAFT', 'CLOSED');
+
+ALTER TYPE pr_status RENAME TO pr_status_old;
+CREATE TYPE pr_status AS ENUM ('OPEN', 'MERGED');
+
+ALTER TABLE pull_requests
+	ALTER COLUMN status DROP DEFAULT,
+	ALTER COLUMN status TYPE pr_status USING status::text::pr_status,
+	ALTER COLUMN status SET DEFAULT 'OPEN';
+
+DROP TYPE pr_status_old;
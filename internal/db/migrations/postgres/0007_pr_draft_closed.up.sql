@@ -0,0 +1,5 @@
+-- DRAFT — PR без ревьюверов до перевода в OPEN; CLOSED — PR закрыт без мерджа.
+ALTER TYPE pr_status ADD VALUE IF NOT EXISTS 'DRAFT' BEFORE 'OPEN';
+ALTER TYPE pr_status ADD VALUE IF NOT EXISTS 'CLOSED';
+
+ALTER TABLE pull_requests ADD COLUMN IF NOT EXISTS closed_at TIMESTAMPTZ;
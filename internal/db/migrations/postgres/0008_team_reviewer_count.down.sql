@@ -0,0 +1,4 @@
+ALTER TABLE teams
+	DROP COLUMN IF EXISTS max_reviewers,
+	DROP COLUMN IF EXISTS min_reviewers,
+	DROP COLUMN IF EXISTS reviewer_count;
@@ -0,0 +1,6 @@
+-- Сколько ревьюверов назначать на PR команды и границы ручного изменения списка.
+-- max_reviewers = 0 — без ограничения.
+ALTER TABLE teams
+	ADD COLUMN IF NOT EXISTS reviewer_count INT NOT NULL DEFAULT 2 CHECK (reviewer_count >= 0),
+	ADD COLUMN IF NOT EXISTS min_reviewers INT NOT NULL DEFAULT 0 CHECK (min_reviewers >= 0),
+	ADD COLUMN IF NOT EXISTS max_reviewers INT NOT NULL DEFAULT 0 CHECK (max_reviewers >= 0);
@@ -0,0 +1,13 @@
+-- Резервные команды: откуда брать ревьюверов, если в команде автора
+-- не хватает кандидатов. Обходятся по возрастанию position.
+CREATE TABLE IF NOT EXISTS team_fallbacks (
+	team_name     TEXT NOT NULL REFERENCES teams(name) ON DELETE CASCADE,
+	fallback_team TEXT NOT NULL REFERENCES teams(name) ON DELETE CASCADE,
+	position      INT NOT NULL,
+	PRIMARY KEY (team_name, fallback_team),
+	CHECK (team_name <> fallback_team)
+);
+
+-- Резервная команда, из которой взят ревьювер ('' — из основной).
+ALTER TABLE pull_request_reviewers
+	ADD COLUMN IF NOT EXISTS fallback_team TEXT NOT NULL DEFAULT '';
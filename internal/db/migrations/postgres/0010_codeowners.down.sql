@@ -0,0 +1,4 @@
+ALTER TABLE pull_request_reviewers DROP COLUMN IF EXISTS owner_pattern;
+
+DROP TABLE IF EXISTS team_codeowners;
+DROP TABLE IF EXISTS pull_request_paths;
@@ -0,0 +1,6 @@
+ALTER TABLE pull_request_reviewers
+	DROP COLUMN IF EXISTS matched_tags,
+	DROP COLUMN IF EXISTS match_reason;
+
+ALTER TABLE pull_requests DROP COLUMN IF EXISTS labels;
+ALTER TABLE users DROP COLUMN IF EXISTS tags;
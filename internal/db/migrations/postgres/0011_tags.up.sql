@@ -0,0 +1,15 @@
+-- Теги экспертизы пользователя и метки PR — через пробел, в нижнем регистре.
+ALTER TABLE users
+	ADD COLUMN IF NOT EXISTS tags TEXT NOT NULL DEFAULT '';
+ALTER TABLE pull_requests
+	ADD COLUMN IF NOT EXISTS labels TEXT NOT NULL DEFAULT '';
+
+-- Почему ревьювер назначен (CODEOWNER, TAG_MATCH, TEAM, FALLBACK_TEAM, MANUAL)
+-- и какие его теги совпали с метками PR.
+ALTER TABLE pull_request_reviewers
+	ADD COLUMN IF NOT EXISTS match_reason TEXT NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS matched_tags TEXT NOT NULL DEFAULT '';
+
+UPDATE pull_request_reviewers
+SET match_reason = CASE WHEN owner_pattern <> '' THEN 'CODEOWNER' ELSE 'FALLBACK_TEAM' END
+WHERE owner_pattern <> '' OR fallback_team <> '';
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS pull_requests_author_created_idx;
+
+ALTER TABLE teams DROP COLUMN IF EXISTS rotation_window;
@@ -0,0 +1,7 @@
+-- Сколько последних PR автора учитывает стратегия rotation (0 — не учитывать).
+ALTER TABLE teams
+	ADD COLUMN IF NOT EXISTS rotation_window INT NOT NULL DEFAULT 5 CHECK (rotation_window >= 0);
+
+-- Последние PR автора для стратегии rotation.
+CREATE INDEX IF NOT EXISTS pull_requests_author_created_idx
+	ON pull_requests(author_id, created_at DESC);
@@ -0,0 +1,8 @@
+-- Уровень участника: senior и lead считаются старшими ревьюверами.
+ALTER TABLE users
+	ADD COLUMN IF NOT EXISTS level TEXT NOT NULL DEFAULT 'mid'
+		CHECK (level IN ('junior', 'mid', 'senior', 'lead'));
+
+-- Требовать среди ревьюверов PR автора из команды хотя бы одного старшего.
+ALTER TABLE teams
+	ADD COLUMN IF NOT EXISTS require_senior BOOLEAN NOT NULL DEFAULT false;
@@ -0,0 +1,16 @@
+-- Журнал автоматического выбора ревьюверов PR: зерно случайности, стратегия,
+-- исключённые пользователи, шаги выбора (пул кандидатов и выбранные) и итог.
+CREATE TABLE IF NOT EXISTS pull_request_assignments (
+	assignment_id   BIGSERIAL PRIMARY KEY,
+	pull_request_id TEXT NOT NULL REFERENCES pull_requests(pull_request_id) ON DELETE CASCADE,
+	kind            TEXT NOT NULL,
+	seed            BIGINT NOT NULL,
+	strategy        TEXT NOT NULL,
+	excluded        JSONB NOT NULL DEFAULT '[]',
+	steps           JSONB NOT NULL DEFAULT '[]',
+	chosen          JSONB NOT NULL DEFAULT '[]',
+	created_at      TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS pull_request_assignments_pr_idx
+	ON pull_request_assignments (pull_request_id, assignment_id);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS pull_request_reviewers_user_idx;
+DROP INDEX IF EXISTS pull_requests_status_created_idx;
+DROP INDEX IF EXISTS pull_requests_merged_idx;
+DROP INDEX IF EXISTS pull_requests_created_idx;
@@ -0,0 +1,16 @@
+-- Список PR (/pullRequest/list): порядок по created_at или merged_at
+-- с pull_request_id для курсора.
+CREATE INDEX IF NOT EXISTS pull_requests_created_idx
+	ON pull_requests(created_at, pull_request_id);
+
+CREATE INDEX IF NOT EXISTS pull_requests_merged_idx
+	ON pull_requests(merged_at, pull_request_id)
+	WHERE merged_at IS NOT NULL;
+
+-- Фильтр по статусу, например открытые PR от новых к старым.
+CREATE INDEX IF NOT EXISTS pull_requests_status_created_idx
+	ON pull_requests(status, created_at, pull_request_id);
+
+-- Фильтр по ревьюверу: первичный ключ начинается с pull_request_id.
+CREATE INDEX IF NOT EXISTS pull_request_reviewers_user_idx
+	ON pull_request_reviewers(user_id);
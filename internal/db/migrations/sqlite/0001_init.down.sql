@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS user_unavailability;
+DROP TABLE IF EXISTS pull_request_reviewers;
+DROP TABLE IF EXISTS pull_requests;
+DROP TABLE IF EXISTS users;
+DROP TABLE IF EXISTS teams;
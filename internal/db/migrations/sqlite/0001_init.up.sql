@@ -0,0 +1,48 @@
+-- Время хранится как TEXT в UTC в формате фиксированной ширины
+-- (2006-01-02T15:04:05.000000000Z), поэтому строковое сравнение
+-- совпадает с хронологическим.
+
+-- Таблица команд.
+CREATE TABLE teams (
+	name                        TEXT PRIMARY KEY,
+	auto_reassign_on_deactivate INTEGER NOT NULL DEFAULT 0
+);
+
+-- Таблица пользователей.
+CREATE TABLE users (
+	user_id          TEXT PRIMARY KEY,
+	username         TEXT NOT NULL,
+	team_name        TEXT NOT NULL REFERENCES teams(name) ON DELETE RESTRICT,
+	is_active        INTEGER NOT NULL DEFAULT 1,
+	max_open_reviews INTEGER NOT NULL DEFAULT 0 CHECK (max_open_reviews >= 0)
+);
+
+-- Таблица Pull Requests. Вместо enum pr_status — CHECK.
+CREATE TABLE pull_requests (
+	pull_request_id   TEXT PRIMARY KEY,
+	pull_request_name TEXT NOT NULL,
+	author_id         TEXT NOT NULL REFERENCES users(user_id),
+	status            TEXT NOT NULL DEFAULT 'OPEN' CHECK (status IN ('OPEN', 'MERGED')),
+	created_at        TEXT NOT NULL,
+	merged_at         TEXT
+);
+
+-- Таблица связи PR и ревьюверов (many-to-many).
+CREATE TABLE pull_request_reviewers (
+	pull_request_id TEXT NOT NULL REFERENCES pull_requests(pull_request_id) ON DELETE CASCADE,
+	user_id         TEXT NOT NULL REFERENCES users(user_id),
+	PRIMARY KEY (pull_request_id, user_id)
+);
+
+-- Окна недоступности пользователей.
+CREATE TABLE user_unavailability (
+	window_id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id   TEXT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+	starts_at TEXT NOT NULL,
+	ends_at   TEXT NOT NULL,
+	reason    TEXT NOT NULL DEFAULT '',
+	CHECK (ends_at > starts_at)
+);
+
+CREATE INDEX user_unavailability_user_idx
+	ON user_unavailability (user_id, ends_at);
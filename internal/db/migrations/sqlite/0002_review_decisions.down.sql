@@ -0,0 +1,3 @@
+ALTER TABLE pull_request_reviewers DROP COLUMN submitted_at;
+ALTER TABLE pull_request_reviewers DROP COLUMN assigned_at;
+ALTER TABLE pull_request_reviewers DROP COLUMN state;
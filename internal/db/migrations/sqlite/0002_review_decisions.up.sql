@@ -0,0 +1,15 @@
+-- Решение ревьювера по PR: PENDING, пока ревью не отправлено.
+ALTER TABLE pull_request_reviewers
+	ADD COLUMN state TEXT NOT NULL DEFAULT 'PENDING'
+	CHECK (state IN ('PENDING', 'APPROVED', 'CHANGES_REQUESTED', 'COMMENTED'));
+
+-- SQLite не разрешает неконстантный DEFAULT в ADD COLUMN:
+-- время назначения всегда передаёт приложение.
+ALTER TABLE pull_request_reviewers ADD COLUMN assigned_at TEXT NOT NULL DEFAULT '';
+ALTER TABLE pull_request_reviewers ADD COLUMN submitted_at TEXT;
+
+UPDATE pull_request_reviewers
+SET assigned_at = (
+	SELECT pr.created_at FROM pull_requests pr
+	WHERE pr.pull_request_id = pull_request_reviewers.pull_request_id
+);
@@ -0,0 +1,3 @@
+ALTER TABLE pull_requests DROP COLUMN merge_override;
+ALTER TABLE teams DROP COLUMN block_on_changes_requested;
+ALTER TABLE teams DROP COLUMN min_approvals;
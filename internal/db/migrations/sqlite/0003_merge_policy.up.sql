@@ -0,0 +1,6 @@
+-- Политика мерджа команды автора PR.
+ALTER TABLE teams ADD COLUMN min_approvals INTEGER NOT NULL DEFAULT 0 CHECK (min_approvals >= 0);
+ALTER TABLE teams ADD COLUMN block_on_changes_requested INTEGER NOT NULL DEFAULT 0;
+
+-- PR замёрджен в обход политики.
+ALTER TABLE pull_requests ADD COLUMN merge_override INTEGER NOT NULL DEFAULT 0;
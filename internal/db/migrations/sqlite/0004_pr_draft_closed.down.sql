@@ -0,0 +1,25 @@
+-- DRAFT и CLOSED PR возвращаются в OPEN.
+CREATE TEMP TABLE reviewers_backup AS SELECT * FROM pull_request_reviewers;
+
+CREATE TABLE pull_requests_old (
+	pull_request_id   TEXT PRIMARY KEY,
+	pull_request_name TEXT NOT NULL,
+	author_id         TEXT NOT NULL REFERENCES users(user_id),
+	status            TEXT NOT NULL DEFAULT 'OPEN' CHECK (status IN ('OPEN', 'MERGED')),
+	created_at        TEXT NOT NULL,
+	merged_at         TEXT,
+	merge_override    INTEGER NOT NULL DEFAULT 0
+);
+
+INSERT INTO pull_requests_old
+	(pull_request_id, pull_request_name, author_id, status, created_at, merged_at, merge_override)
+SELECT pull_request_id, pull_request_name, author_id,
+	CASE WHEN status IN ('DRAFT', 'CLOSED') THEN 'OPEN' ELSE status END,
+	created_at, merged_at, merge_override
+FROM pull_requests;
+
+DROP TABLE pull_requests;
+ALTER TABLE pull_requests_old RENAME TO pull_requests;
+
+INSERT INTO pull_request_reviewers SELECT * FROM reviewers_backup;
+DROP TABLE reviewers_backup;
@@ -0,0 +1,26 @@
+-- DRAFT — PR без ревьюверов до перевода в OPEN; CLOSED — PR закрыт без мерджа.
+-- CHECK в SQLite не меняется через ALTER, поэтому таблица пересоздаётся.
+-- DROP TABLE каскадно удаляет ревьюверов, их сохраняем во временной таблице.
+CREATE TEMP TABLE reviewers_backup AS SELECT * FROM pull_request_reviewers;
+
+CREATE TABLE pull_requests_new (
+	pull_request_id   TEXT PRIMARY KEY,
+	pull_request_name TEXT NOT NULL,
+	author_id         TEXT NOT NULL REFERENCES users(user_id),
+	status            TEXT NOT NULL DEFAULT 'OPEN' CHECK (status IN ('DRAFT', 'OPEN', 'MERGED', 'CLOSED')),
+	created_at        TEXT NOT NULL,
+	merged_at         TEXT,
+	merge_override    INTEGER NOT NULL DEFAULT 0,
+	closed_at         TEXT
+);
+
+INSERT INTO pull_requests_new
+	(pull_request_id, pull_request_name, author_id, status, created_at, merged_at, merge_override)
+SELECT pull_request_id, pull_request_name, author_id, status, created_at, merged_at, merge_override
+FROM pull_requests;
+
+DROP TABLE pull_requests;
+ALTER TABLE pull_requests_new RENAME TO pull_requests;
+
+INSERT INTO pull_request_reviewers SELECT * FROM reviewers_backup;
+DROP TABLE reviewers_backup;
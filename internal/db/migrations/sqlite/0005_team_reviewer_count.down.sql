@@ -0,0 +1,3 @@
+ALTER TABLE teams DROP COLUMN max_reviewers;
+ALTER TABLE teams DROP COLUMN min_reviewers;
+ALTER TABLE teams DROP COLUMN reviewer_count;
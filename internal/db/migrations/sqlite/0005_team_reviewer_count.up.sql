@@ -0,0 +1,5 @@
+-- Сколько ревьюверов назначать на PR команды и границы ручного изменения списка.
+-- max_reviewers = 0 — без ограничения.
+ALTER TABLE teams ADD COLUMN reviewer_count INTEGER NOT NULL DEFAULT 2 CHECK (reviewer_count >= 0);
+ALTER TABLE teams ADD COLUMN min_reviewers INTEGER NOT NULL DEFAULT 0 CHECK (min_reviewers >= 0);
+ALTER TABLE teams ADD COLUMN max_reviewers INTEGER NOT NULL DEFAULT 0 CHECK (max_reviewers >= 0);
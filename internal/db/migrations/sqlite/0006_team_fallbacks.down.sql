@@ -0,0 +1,3 @@
+ALTER TABLE pull_request_reviewers DROP COLUMN fallback_team;
+
+DROP TABLE team_fallbacks;
@@ -0,0 +1,12 @@
+-- Резервные команды: откуда брать ревьюверов, если в команде автора
+-- не хватает кандидатов. Обходятся по возрастанию position.
+CREATE TABLE team_fallbacks (
+	team_name     TEXT NOT NULL REFERENCES teams(name) ON DELETE CASCADE,
+	fallback_team TEXT NOT NULL REFERENCES teams(name) ON DELETE CASCADE,
+	position      INTEGER NOT NULL,
+	PRIMARY KEY (team_name, fallback_team),
+	CHECK (team_name <> fallback_team)
+);
+
+-- Резервная команда, из которой взят ревьювер ('' — из основной).
+ALTER TABLE pull_request_reviewers ADD COLUMN fallback_team TEXT NOT NULL DEFAULT '';
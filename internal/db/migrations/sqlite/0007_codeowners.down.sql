@@ -0,0 +1,4 @@
+ALTER TABLE pull_request_reviewers DROP COLUMN owner_pattern;
+
+DROP TABLE team_codeowners;
+DROP TABLE pull_request_paths;
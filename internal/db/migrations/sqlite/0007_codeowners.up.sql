@@ -0,0 +1,20 @@
+-- Изменённые файлы PR: по ним выбираются владельцы кода.
+CREATE TABLE pull_request_paths (
+	pull_request_id TEXT NOT NULL REFERENCES pull_requests(pull_request_id) ON DELETE CASCADE,
+	position        INTEGER NOT NULL,
+	path            TEXT NOT NULL,
+	PRIMARY KEY (pull_request_id, path)
+);
+
+-- Правила владения кодом команды в синтаксисе CODEOWNERS.
+-- owners — владельцы через пробел (@user или @org/team).
+CREATE TABLE team_codeowners (
+	team_name TEXT NOT NULL REFERENCES teams(name) ON DELETE CASCADE,
+	position  INTEGER NOT NULL,
+	pattern   TEXT NOT NULL,
+	owners    TEXT NOT NULL,
+	PRIMARY KEY (team_name, position)
+);
+
+-- Шаблон CODEOWNERS, по которому ревьювер назначен владельцем ('' — не владелец).
+ALTER TABLE pull_request_reviewers ADD COLUMN owner_pattern TEXT NOT NULL DEFAULT '';
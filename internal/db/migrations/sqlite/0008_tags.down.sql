@@ -0,0 +1,5 @@
+ALTER TABLE pull_request_reviewers DROP COLUMN matched_tags;
+ALTER TABLE pull_request_reviewers DROP COLUMN match_reason;
+
+ALTER TABLE pull_requests DROP COLUMN labels;
+ALTER TABLE users DROP COLUMN tags;
@@ -0,0 +1,3 @@
+DROP INDEX pull_requests_author_created_idx;
+
+ALTER TABLE teams DROP COLUMN rotation_window;
@@ -0,0 +1,6 @@
+-- Сколько последних PR автора учитывает стратегия rotation (0 — не учитывать).
+ALTER TABLE teams ADD COLUMN rotation_window INTEGER NOT NULL DEFAULT 5 CHECK (rotation_window >= 0);
+
+-- Последние PR автора для стратегии rotation.
+CREATE INDEX pull_requests_author_created_idx
+	ON pull_requests(author_id, created_at DESC);
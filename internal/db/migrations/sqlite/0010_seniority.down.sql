@@ -0,0 +1,2 @@
+ALTER TABLE teams DROP COLUMN require_senior;
+ALTER TABLE users DROP COLUMN level;
@@ -0,0 +1,6 @@
+-- Уровень участника: senior и lead считаются старшими ревьюверами.
+ALTER TABLE users ADD COLUMN level TEXT NOT NULL DEFAULT 'mid'
+	CHECK (level IN ('junior', 'mid', 'senior', 'lead'));
+
+-- Требовать среди ревьюверов PR автора из команды хотя бы одного старшего.
+ALTER TABLE teams ADD COLUMN require_senior INTEGER NOT NULL DEFAULT 0;
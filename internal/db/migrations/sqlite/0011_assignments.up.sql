@@ -0,0 +1,17 @@
+-- Журнал автоматического выбора ревьюверов PR: зерно случайности, стратегия,
+-- исключённые пользователи, шаги выбора (пул кандидатов и выбранные) и итог.
+-- excluded, steps и chosen хранятся JSON-текстом.
+CREATE TABLE pull_request_assignments (
+	assignment_id   INTEGER PRIMARY KEY AUTOINCREMENT,
+	pull_request_id TEXT NOT NULL REFERENCES pull_requests(pull_request_id) ON DELETE CASCADE,
+	kind            TEXT NOT NULL,
+	seed            INTEGER NOT NULL,
+	strategy        TEXT NOT NULL,
+	excluded        TEXT NOT NULL DEFAULT '[]',
+	steps           TEXT NOT NULL DEFAULT '[]',
+	chosen          TEXT NOT NULL DEFAULT '[]',
+	created_at      TEXT NOT NULL
+);
+
+CREATE INDEX pull_request_assignments_pr_idx
+	ON pull_request_assignments (pull_request_id, assignment_id);
@@ -1,8 +1,14 @@
 package httpapi
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
+	"net/url"
+	"strconv"
+	"time"
 
 	"pr-review-service/internal/model"
 	"pr-review-service/internal/service"
@@ -27,13 +33,31 @@ func (h *Handler) Router() http.Handler {
 
 	r.HandleFunc("/team/add", h.handleTeamAdd).Methods("POST")
 	r.HandleFunc("/team/get", h.handleTeamGet).Methods("GET")
+	r.HandleFunc("/team/settings", h.handleTeamSettingsGet).Methods("GET")
+	r.HandleFunc("/team/settings", h.handleTeamSettingsUpdate).Methods("POST")
+	r.HandleFunc("/team/codeowners", h.handleCodeownersGet).Methods("GET")
+	r.HandleFunc("/team/codeowners", h.handleCodeownersUpdate).Methods("POST")
 
 	r.HandleFunc("/users/setIsActive", h.handleSetIsActive).Methods("POST")
+	r.HandleFunc("/users/update", h.handleUserUpdate).Methods("POST")
+	r.HandleFunc("/users/addUnavailability", h.handleAddUnavailability).Methods("POST")
+	r.HandleFunc("/users/getUnavailability", h.handleGetUnavailability).Methods("GET")
+	r.HandleFunc("/users/deleteUnavailability", h.handleDeleteUnavailability).Methods("POST")
 	r.HandleFunc("/users/getReview", h.handleUserReviews).Methods("GET")
 
 	r.HandleFunc("/pullRequest/create", h.handlePRCreate).Methods("POST")
 	r.HandleFunc("/pullRequest/merge", h.handlePRMerge).Methods("POST")
 	r.HandleFunc("/pullRequest/reassign", h.handlePRReassign).Methods("POST")
+	r.HandleFunc("/pullRequest/review", h.handlePRReview).Methods("POST")
+	r.HandleFunc("/pullRequest/addReviewer", h.handlePRAddReviewer).Methods("POST")
+	r.HandleFunc("/pullRequest/removeReviewer", h.handlePRRemoveReviewer).Methods("POST")
+	r.HandleFunc("/pullRequest/fillReviewers", h.handlePRFillReviewers).Methods("POST")
+	r.HandleFunc("/pullRequest/markReady", h.handlePRTransition(h.svc.MarkReady)).Methods("POST")
+	r.HandleFunc("/pullRequest/close", h.handlePRTransition(h.svc.ClosePR)).Methods("POST")
+	r.HandleFunc("/pullRequest/reopen", h.handlePRTransition(h.svc.ReopenPR)).Methods("POST")
+	r.HandleFunc("/pullRequest/assignments", h.handlePRAssignments).Methods("GET")
+	r.HandleFunc("/pullRequest/previewReviewers", h.handlePRPreviewReviewers).Methods("GET")
+	r.HandleFunc("/pullRequest/list", h.handlePRList).Methods("GET")
 
 	r.HandleFunc("/stats/reviewerAssignments", h.handleReviewerStats).Methods("GET")
 
@@ -55,9 +79,25 @@ const (
 	CodeTeamExists  ErrorCode = "TEAM_EXISTS"
 	CodePRExists    ErrorCode = "PR_EXISTS"
 	CodePRMerged    ErrorCode = "PR_MERGED"
+	CodePRClosed    ErrorCode = "PR_CLOSED"
+	CodePRDraft     ErrorCode = "PR_DRAFT"
 	CodeNotAssigned ErrorCode = "NOT_ASSIGNED"
 	CodeNoCandidate ErrorCode = "NO_CANDIDATE"
 	CodeNotFound    ErrorCode = "NOT_FOUND"
+	CodeAtCapacity  ErrorCode = "AT_CAPACITY"
+	CodeInvalid     ErrorCode = "INVALID_ARGUMENT"
+	CodeNotApproved ErrorCode = "NOT_APPROVED"
+
+	CodeUserInactive    ErrorCode = "USER_INACTIVE"
+	CodeUserUnavailable ErrorCode = "USER_UNAVAILABLE"
+	CodeIsAuthor        ErrorCode = "IS_AUTHOR"
+	CodeAlreadyAssigned ErrorCode = "ALREADY_ASSIGNED"
+	CodeNotInTeam       ErrorCode = "NOT_IN_TEAM"
+
+	CodeMaxReviewers ErrorCode = "MAX_REVIEWERS"
+	CodeMinReviewers ErrorCode = "MIN_REVIEWERS"
+
+	CodeSeniorRequired ErrorCode = "SENIOR_REQUIRED"
 )
 
 /*
@@ -65,18 +105,25 @@ ErrorResponse соответствует формату ошибок OpenAPI.
 */
 type ErrorResponse struct {
 	Error struct {
-		Code    ErrorCode `json:"code"`
-		Message string    `json:"message"`
+		Code    ErrorCode   `json:"code"`
+		Message string      `json:"message"`
+		Details interface{} `json:"details,omitempty"`
 	} `json:"error"`
 }
 
 // writeError записывает ошибку в правильном OpenAPI-формате
 func writeError(w http.ResponseWriter, status int, code ErrorCode, msg string) {
+	writeErrorDetails(w, status, code, msg, nil)
+}
+
+// writeErrorDetails записывает ошибку с дополнительными сведениями в поле details.
+func writeErrorDetails(w http.ResponseWriter, status int, code ErrorCode, msg string, details interface{}) {
 	w.WriteHeader(status)
 
 	resp := ErrorResponse{}
 	resp.Error.Code = code
 	resp.Error.Message = msg
+	resp.Error.Details = details
 
 	if err := json.NewEncoder(w).Encode(resp); err != nil {
 		// Пишем только в HTTP-лог, но не возвращаем ошибку наружу
@@ -92,19 +139,25 @@ func (h *Handler) handleTeamAdd(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	team, err := h.svc.CreateTeam(r.Context(), t)
+	team, fills, err := h.svc.CreateTeam(r.Context(), t)
 	if err != nil {
 		switch err {
 		case service.ErrTeamExists:
 			writeError(w, 400, CodeTeamExists, "team already exists")
+		case service.ErrInvalid:
+			writeError(w, 400, CodeInvalid, "invalid max_open_reviews, tags or level")
 		default:
 			w.WriteHeader(500)
 		}
 		return
 	}
 
+	resp := map[string]interface{}{"team": team}
+	if len(fills) > 0 {
+		resp["filled"] = fills
+	}
 	w.WriteHeader(201)
-	if err := json.NewEncoder(w).Encode(map[string]interface{}{"team": team}); err != nil {
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
 		_ = err
 	}
 }
@@ -128,18 +181,151 @@ func (h *Handler) handleTeamGet(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// handleTeamSettingsGet обрабатывает GET /team/settings?team_name=...
+func (h *Handler) handleTeamSettingsGet(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("team_name")
+	if name == "" {
+		w.WriteHeader(400)
+		return
+	}
+
+	ts, err := h.svc.GetTeamSettings(r.Context(), name)
+	if err != nil {
+		if err == service.ErrNotFound {
+			writeError(w, 404, CodeNotFound, "team not found")
+			return
+		}
+		w.WriteHeader(500)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{"settings": ts}); err != nil {
+		_ = err
+	}
+}
+
+/*
+handleTeamSettingsUpdate обрабатывает POST /team/settings.
+Поля, которых нет в запросе, сохраняют текущие значения.
+*/
+func (h *Handler) handleTeamSettingsUpdate(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	var req struct {
+		TeamName string `json:"team_name"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil || req.TeamName == "" {
+		w.WriteHeader(400)
+		return
+	}
+
+	ts, err := h.svc.GetTeamSettings(r.Context(), req.TeamName)
+	if err != nil {
+		if err == service.ErrNotFound {
+			writeError(w, 404, CodeNotFound, "team not found")
+			return
+		}
+		w.WriteHeader(500)
+		return
+	}
+
+	if err := json.Unmarshal(body, ts); err != nil {
+		w.WriteHeader(400)
+		return
+	}
+	ts.TeamName = req.TeamName
+
+	ts, err = h.svc.UpdateTeamSettings(r.Context(), *ts)
+	if err != nil {
+		switch err {
+		case service.ErrNotFound:
+			writeError(w, 404, CodeNotFound, "team not found")
+		case service.ErrInvalid:
+			writeError(w, 400, CodeInvalid, "invalid team settings")
+		default:
+			w.WriteHeader(500)
+		}
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{"settings": ts}); err != nil {
+		_ = err
+	}
+}
+
+// handleCodeownersGet обрабатывает GET /team/codeowners?team_name=...
+func (h *Handler) handleCodeownersGet(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("team_name")
+	if name == "" {
+		w.WriteHeader(400)
+		return
+	}
+
+	rules, err := h.svc.GetCodeowners(r.Context(), name)
+	if err != nil {
+		if err == service.ErrNotFound {
+			writeError(w, 404, CodeNotFound, "team not found")
+			return
+		}
+		w.WriteHeader(500)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{"team_name": name, "rules": rules}); err != nil {
+		_ = err
+	}
+}
+
+/*
+handleCodeownersUpdate обрабатывает POST /team/codeowners.
+Правила целиком заменяются текстом в синтаксисе CODEOWNERS.
+*/
+func (h *Handler) handleCodeownersUpdate(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		TeamName   string `json:"team_name"`
+		Codeowners string `json:"codeowners"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.TeamName == "" {
+		w.WriteHeader(400)
+		return
+	}
+
+	rules, err := h.svc.SetCodeowners(r.Context(), req.TeamName, req.Codeowners)
+	if err != nil {
+		var ce *service.CodeownersError
+		switch {
+		case errors.As(err, &ce):
+			writeError(w, 400, CodeInvalid, ce.Error())
+		case err == service.ErrNotFound:
+			writeError(w, 404, CodeNotFound, "team not found")
+		default:
+			w.WriteHeader(500)
+		}
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{"team_name": req.TeamName, "rules": rules}); err != nil {
+		_ = err
+	}
+}
+
 // handleSetIsActive обрабатывает POST /users/setIsActive.
 func (h *Handler) handleSetIsActive(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		UserID   string `json:"user_id"`
 		IsActive bool   `json:"is_active"`
+		Reassign *bool  `json:"reassign_open_reviews"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		w.WriteHeader(400)
 		return
 	}
 
-	u, err := h.svc.SetUserIsActive(r.Context(), req.UserID, req.IsActive)
+	u, report, fills, err := h.svc.SetUserIsActive(r.Context(), req.UserID, req.IsActive, req.Reassign)
 	if err != nil {
 		if err == service.ErrNotFound {
 			writeError(w, 404, CodeNotFound, "user not found")
@@ -149,31 +335,143 @@ func (h *Handler) handleSetIsActive(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	resp := map[string]interface{}{"user": u}
+	if report != nil {
+		resp["reassignment"] = report
+	}
+	if fills != nil {
+		resp["filled"] = fills
+	}
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		_ = err
+	}
+}
+
+// handleUserUpdate обрабатывает POST /users/update.
+func (h *Handler) handleUserUpdate(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		UserID string `json:"user_id"`
+		model.UserUpdate
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	u, err := h.svc.UpdateUser(r.Context(), req.UserID, req.UserUpdate)
+	if err != nil {
+		switch err {
+		case service.ErrNotFound:
+			writeError(w, 404, CodeNotFound, "user not found")
+		case service.ErrInvalid:
+			writeError(w, 400, CodeInvalid, "invalid max_open_reviews, tags or level")
+		default:
+			w.WriteHeader(500)
+		}
+		return
+	}
+
 	if err := json.NewEncoder(w).Encode(map[string]interface{}{"user": u}); err != nil {
 		_ = err
 	}
 }
 
-// handlePRCreate обрабатывает POST /pullRequest/create
-func (h *Handler) handlePRCreate(w http.ResponseWriter, r *http.Request) {
+// handleAddUnavailability обрабатывает POST /users/addUnavailability.
+func (h *Handler) handleAddUnavailability(w http.ResponseWriter, r *http.Request) {
+	var req model.UnavailabilityWindow
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	win, err := h.svc.AddUnavailability(r.Context(), req)
+	if err != nil {
+		switch err {
+		case service.ErrNotFound:
+			writeError(w, 404, CodeNotFound, "user not found")
+		case service.ErrInvalid:
+			writeError(w, 400, CodeInvalid, "ends_at must be after starts_at")
+		default:
+			w.WriteHeader(500)
+		}
+		return
+	}
+
+	w.WriteHeader(201)
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{"window": win}); err != nil {
+		_ = err
+	}
+}
+
+// handleGetUnavailability обрабатывает GET /users/getUnavailability?user_id=...
+func (h *Handler) handleGetUnavailability(w http.ResponseWriter, r *http.Request) {
+	uid := r.URL.Query().Get("user_id")
+	if uid == "" {
+		w.WriteHeader(400)
+		return
+	}
+
+	list, err := h.svc.ListUnavailability(r.Context(), uid)
+	if err != nil {
+		if err == service.ErrNotFound {
+			writeError(w, 404, CodeNotFound, "user not found")
+			return
+		}
+		w.WriteHeader(500)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"user_id": uid,
+		"windows": list,
+	}); err != nil {
+		_ = err
+	}
+}
+
+// handleDeleteUnavailability обрабатывает POST /users/deleteUnavailability.
+func (h *Handler) handleDeleteUnavailability(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		ID     string `json:"pull_request_id"`
-		Name   string `json:"pull_request_name"`
-		Author string `json:"author_id"`
+		ID int64 `json:"window_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	if err := h.svc.DeleteUnavailability(r.Context(), req.ID); err != nil {
+		if err == service.ErrNotFound {
+			writeError(w, 404, CodeNotFound, "window not found")
+			return
+		}
+		w.WriteHeader(500)
+		return
 	}
 
+	w.WriteHeader(204)
+}
+
+// handlePRCreate обрабатывает POST /pullRequest/create
+func (h *Handler) handlePRCreate(w http.ResponseWriter, r *http.Request) {
+	var req model.NewPullRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		w.WriteHeader(400)
 		return
 	}
 
-	pr, err := h.svc.CreatePR(r.Context(), req.ID, req.Name, req.Author)
+	pr, err := h.svc.CreatePR(r.Context(), req)
 	if err != nil {
 		switch err {
 		case service.ErrPRExists:
 			writeError(w, 409, CodePRExists, "PR already exists")
+		case service.ErrInvalid:
+			writeError(w, 400, CodeInvalid, "changed_paths and labels must not contain empty values")
 		case service.ErrNotFound:
 			writeError(w, 404, CodeNotFound, "author not found")
+		case service.ErrAtCapacity:
+			writeError(w, 409, CodeAtCapacity, "all candidates reached max_open_reviews")
+		case service.ErrSeniorRequired:
+			writeError(w, 409, CodeSeniorRequired, "team requires a senior reviewer and none is available")
 		default:
 			w.WriteHeader(500)
 		}
@@ -189,7 +487,8 @@ func (h *Handler) handlePRCreate(w http.ResponseWriter, r *http.Request) {
 // handlePRMerge обрабатывает POST /pullRequest/merge
 func (h *Handler) handlePRMerge(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		ID string `json:"pull_request_id"`
+		ID       string `json:"pull_request_id"`
+		Override bool   `json:"override"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -197,13 +496,23 @@ func (h *Handler) handlePRMerge(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	pr, err := h.svc.MergePR(r.Context(), req.ID)
+	pr, err := h.svc.MergePR(r.Context(), req.ID, req.Override)
 	if err != nil {
-		if err == service.ErrNotFound {
-			writeError(w, 404, CodeNotFound, "pr not found")
+		var notApproved *service.NotApprovedError
+		if errors.As(err, &notApproved) {
+			writeErrorDetails(w, 409, CodeNotApproved, "merge policy of the team is not satisfied", notApproved.Status)
 			return
 		}
-		w.WriteHeader(500)
+		switch err {
+		case service.ErrPRDraft:
+			writeError(w, 409, CodePRDraft, "cannot merge draft PR")
+		case service.ErrPRClosed:
+			writeError(w, 409, CodePRClosed, "cannot merge closed PR")
+		case service.ErrNotFound:
+			writeError(w, 404, CodeNotFound, "pr not found")
+		default:
+			w.WriteHeader(500)
+		}
 		return
 	}
 
@@ -212,11 +521,57 @@ func (h *Handler) handlePRMerge(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+/*
+handlePRTransition обрабатывает переходы жизненного цикла PR:
+POST /pullRequest/markReady, /pullRequest/close и /pullRequest/reopen.
+*/
+func (h *Handler) handlePRTransition(
+	transition func(ctx context.Context, id string) (*model.PullRequest, error)) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID string `json:"pull_request_id"`
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(400)
+			return
+		}
+
+		pr, err := transition(r.Context(), req.ID)
+		if err != nil {
+			switch err {
+			case service.ErrPRMerged:
+				writeError(w, 409, CodePRMerged, "PR is already merged")
+			case service.ErrPRClosed:
+				writeError(w, 409, CodePRClosed, "PR is closed")
+			case service.ErrPRDraft:
+				writeError(w, 409, CodePRDraft, "PR is a draft")
+			case service.ErrAtCapacity:
+				writeError(w, 409, CodeAtCapacity, "all candidates reached max_open_reviews")
+			case service.ErrSeniorRequired:
+				writeError(w, 409, CodeSeniorRequired, "team requires a senior reviewer and none is available")
+			case service.ErrNotFound:
+				writeError(w, 404, CodeNotFound, "pr not found")
+			default:
+				w.WriteHeader(500)
+			}
+			return
+		}
+
+		if err := json.NewEncoder(w).Encode(map[string]interface{}{"pr": pr}); err != nil {
+			_ = err
+		}
+	}
+}
+
 // handlePRReassign обрабатывает POST /pullRequest/reassign
 func (h *Handler) handlePRReassign(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		ID  string `json:"pull_request_id"`
-		Old string `json:"old_user_id"`
+		ID       string `json:"pull_request_id"`
+		Old      string `json:"old_user_id"`
+		New      string `json:"new_user_id"`
+		SameTeam bool   `json:"same_team"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -224,15 +579,37 @@ func (h *Handler) handlePRReassign(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	pr, newReviewer, err := h.svc.ReassignReviewer(r.Context(), req.ID, req.Old)
+	pr, newReviewer, err := h.svc.ReassignReviewer(r.Context(), req.ID, req.Old, req.New, req.SameTeam)
 	if err != nil {
 		switch err {
 		case service.ErrPRMerged:
 			writeError(w, 409, CodePRMerged, "cannot reassign on merged PR")
+		case service.ErrPRClosed:
+			writeError(w, 409, CodePRClosed, "cannot reassign on closed PR")
+		case service.ErrPRDraft:
+			writeError(w, 409, CodePRDraft, "draft PR has no reviewers")
 		case service.ErrNotAssigned:
 			writeError(w, 409, CodeNotAssigned, "user not assigned as reviewer")
 		case service.ErrNoCandidate:
 			writeError(w, 409, CodeNoCandidate, "no candidate available")
+		case service.ErrAtCapacity:
+			msg := "all candidates reached max_open_reviews"
+			if req.New != "" {
+				msg = "new reviewer reached max_open_reviews"
+			}
+			writeError(w, 409, CodeAtCapacity, msg)
+		case service.ErrSeniorRequired:
+			writeError(w, 409, CodeSeniorRequired, "team requires a senior reviewer and none is available")
+		case service.ErrUserInactive:
+			writeError(w, 409, CodeUserInactive, "new reviewer is not active")
+		case service.ErrUserUnavailable:
+			writeError(w, 409, CodeUserUnavailable, "new reviewer is unavailable")
+		case service.ErrIsAuthor:
+			writeError(w, 409, CodeIsAuthor, "author cannot review own PR")
+		case service.ErrAlreadyAssigned:
+			writeError(w, 409, CodeAlreadyAssigned, "new reviewer already assigned")
+		case service.ErrNotInTeam:
+			writeError(w, 409, CodeNotInTeam, "new reviewer is not in the team of the old one")
 		case service.ErrNotFound:
 			writeError(w, 404, CodeNotFound, "not found")
 		default:
@@ -249,6 +626,298 @@ func (h *Handler) handlePRReassign(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// handlePRAddReviewer обрабатывает POST /pullRequest/addReviewer
+func (h *Handler) handlePRAddReviewer(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID     string `json:"pull_request_id"`
+		UserID string `json:"user_id"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	pr, err := h.svc.AddReviewer(r.Context(), req.ID, req.UserID)
+	if err != nil {
+		switch err {
+		case service.ErrPRMerged:
+			writeError(w, 409, CodePRMerged, "cannot add reviewer to merged PR")
+		case service.ErrPRClosed:
+			writeError(w, 409, CodePRClosed, "cannot add reviewer to closed PR")
+		case service.ErrPRDraft:
+			writeError(w, 409, CodePRDraft, "reviewers are assigned when draft PR is ready")
+		case service.ErrUserInactive:
+			writeError(w, 409, CodeUserInactive, "user is not active")
+		case service.ErrUserUnavailable:
+			writeError(w, 409, CodeUserUnavailable, "user is unavailable")
+		case service.ErrAtCapacity:
+			writeError(w, 409, CodeAtCapacity, "user reached max_open_reviews")
+		case service.ErrIsAuthor:
+			writeError(w, 409, CodeIsAuthor, "author cannot review own PR")
+		case service.ErrAlreadyAssigned:
+			writeError(w, 409, CodeAlreadyAssigned, "user already assigned as reviewer")
+		case service.ErrMaxReviewers:
+			writeError(w, 409, CodeMaxReviewers, "PR already has max_reviewers reviewers")
+		case service.ErrNotFound:
+			writeError(w, 404, CodeNotFound, "not found")
+		default:
+			w.WriteHeader(500)
+		}
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{"pr": pr}); err != nil {
+		_ = err
+	}
+}
+
+// handlePRRemoveReviewer обрабатывает POST /pullRequest/removeReviewer
+func (h *Handler) handlePRRemoveReviewer(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID     string `json:"pull_request_id"`
+		UserID string `json:"user_id"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	pr, err := h.svc.RemoveReviewer(r.Context(), req.ID, req.UserID)
+	if err != nil {
+		switch err {
+		case service.ErrPRMerged:
+			writeError(w, 409, CodePRMerged, "cannot remove reviewer from merged PR")
+		case service.ErrPRClosed:
+			writeError(w, 409, CodePRClosed, "cannot remove reviewer from closed PR")
+		case service.ErrPRDraft:
+			writeError(w, 409, CodePRDraft, "draft PR has no reviewers")
+		case service.ErrNotAssigned:
+			writeError(w, 409, CodeNotAssigned, "user not assigned as reviewer")
+		case service.ErrMinReviewers:
+			writeError(w, 409, CodeMinReviewers, "PR would have fewer than min_reviewers reviewers")
+		case service.ErrSeniorRequired:
+			writeError(w, 409, CodeSeniorRequired, "team requires a senior reviewer and none is available")
+		case service.ErrNotFound:
+			writeError(w, 404, CodeNotFound, "pr not found")
+		default:
+			w.WriteHeader(500)
+		}
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{"pr": pr}); err != nil {
+		_ = err
+	}
+}
+
+// handlePRFillReviewers обрабатывает POST /pullRequest/fillReviewers
+func (h *Handler) handlePRFillReviewers(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID string `json:"pull_request_id"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	pr, added, err := h.svc.FillReviewers(r.Context(), req.ID)
+	if err != nil {
+		switch err {
+		case service.ErrPRMerged:
+			writeError(w, 409, CodePRMerged, "cannot fill reviewers of merged PR")
+		case service.ErrPRClosed:
+			writeError(w, 409, CodePRClosed, "cannot fill reviewers of closed PR")
+		case service.ErrPRDraft:
+			writeError(w, 409, CodePRDraft, "reviewers are assigned when draft PR is ready")
+		case service.ErrAtCapacity:
+			writeError(w, 409, CodeAtCapacity, "all candidates reached max_open_reviews")
+		case service.ErrNotFound:
+			writeError(w, 404, CodeNotFound, "pr not found")
+		default:
+			w.WriteHeader(500)
+		}
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{"pr": pr, "added": added}); err != nil {
+		_ = err
+	}
+}
+
+// handlePRReview обрабатывает POST /pullRequest/review
+func (h *Handler) handlePRReview(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID     string            `json:"pull_request_id"`
+		UserID string            `json:"user_id"`
+		State  model.ReviewState `json:"state"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	pr, err := h.svc.SubmitReview(r.Context(), req.ID, req.UserID, req.State)
+	if err != nil {
+		switch err {
+		case service.ErrInvalid:
+			writeError(w, 400, CodeInvalid, "state must be APPROVED, CHANGES_REQUESTED or COMMENTED")
+		case service.ErrPRMerged:
+			writeError(w, 409, CodePRMerged, "cannot review merged PR")
+		case service.ErrPRClosed:
+			writeError(w, 409, CodePRClosed, "cannot review closed PR")
+		case service.ErrPRDraft:
+			writeError(w, 409, CodePRDraft, "draft PR has no reviewers")
+		case service.ErrNotAssigned:
+			writeError(w, 409, CodeNotAssigned, "user not assigned as reviewer")
+		case service.ErrNotFound:
+			writeError(w, 404, CodeNotFound, "pr not found")
+		default:
+			w.WriteHeader(500)
+		}
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{"pr": pr}); err != nil {
+		_ = err
+	}
+}
+
+// handlePRAssignments обрабатывает GET /pullRequest/assignments?pull_request_id=...
+func (h *Handler) handlePRAssignments(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("pull_request_id")
+	if id == "" {
+		w.WriteHeader(400)
+		return
+	}
+
+	list, err := h.svc.GetAssignments(r.Context(), id)
+	if err != nil {
+		if err == service.ErrNotFound {
+			writeError(w, 404, CodeNotFound, "pr not found")
+			return
+		}
+		w.WriteHeader(500)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"pull_request_id": id,
+		"assignments":     list,
+	}); err != nil {
+		_ = err
+	}
+}
+
+/*
+handlePRPreviewReviewers обрабатывает GET /pullRequest/previewReviewers?author_id=...
+Изменённые файлы и метки передаются повторяющимися параметрами
+changed_paths и labels.
+*/
+func (h *Handler) handlePRPreviewReviewers(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	author := q.Get("author_id")
+	if author == "" {
+		w.WriteHeader(400)
+		return
+	}
+
+	preview, err := h.svc.PreviewReviewers(r.Context(), author, q["changed_paths"], q["labels"])
+	if err != nil {
+		switch err {
+		case service.ErrInvalid:
+			writeError(w, 400, CodeInvalid, "changed_paths and labels must not contain empty values")
+		case service.ErrNotFound:
+			writeError(w, 404, CodeNotFound, "author not found")
+		case service.ErrAtCapacity:
+			writeError(w, 409, CodeAtCapacity, "all candidates reached max_open_reviews")
+		case service.ErrSeniorRequired:
+			writeError(w, 409, CodeSeniorRequired, "team requires a senior reviewer and none is available")
+		default:
+			w.WriteHeader(500)
+		}
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{"preview": preview}); err != nil {
+		_ = err
+	}
+}
+
+/*
+handlePRList обрабатывает GET /pullRequest/list. Статусы передаются
+повторяющимся параметром status, границы дат — в RFC 3339.
+*/
+func (h *Handler) handlePRList(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f := model.PullRequestFilter{
+		AuthorID:   q.Get("author_id"),
+		ReviewerID: q.Get("reviewer_id"),
+		Team:       q.Get("team_name"),
+		Sort:       model.PullRequestSort(q.Get("sort")),
+	}
+	for _, st := range q["status"] {
+		f.Statuses = append(f.Statuses, model.PullRequestStatus(st))
+	}
+
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeError(w, 400, CodeInvalid, "limit must be a positive integer")
+			return
+		}
+		f.Limit = n
+	}
+
+	bounds := []struct {
+		name string
+		dst  **time.Time
+	}{
+		{"created_from", &f.CreatedFrom},
+		{"created_to", &f.CreatedTo},
+		{"merged_from", &f.MergedFrom},
+		{"merged_to", &f.MergedTo},
+	}
+	for _, b := range bounds {
+		t, err := timeParam(q, b.name)
+		if err != nil {
+			writeError(w, 400, CodeInvalid, b.name+" must be an RFC 3339 timestamp")
+			return
+		}
+		*b.dst = t
+	}
+
+	page, err := h.svc.ListPullRequests(r.Context(), f, q.Get("cursor"))
+	if err != nil {
+		if err == service.ErrInvalid {
+			writeError(w, 400, CodeInvalid, "invalid status, sort, limit, date range or cursor")
+			return
+		}
+		w.WriteHeader(500)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(page); err != nil {
+		_ = err
+	}
+}
+
+// timeParam разбирает необязательный параметр запроса со временем в RFC 3339.
+func timeParam(q url.Values, name string) (*time.Time, error) {
+	v := q.Get(name)
+	if v == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
 // handleUserReviews обрабатывает GET /users/getReview?user_id=...
 func (h *Handler) handleUserReviews(w http.ResponseWriter, r *http.Request) {
 	uid := r.URL.Query().Get("user_id")
@@ -4,9 +4,16 @@ import "time"
 
 // TeamMember описывает участника команды
 type TeamMember struct {
-	UserID   string `json:"user_id"`
-	Username string `json:"username"`
-	IsActive bool   `json:"is_active"`
+	UserID         string `json:"user_id"`
+	Username       string `json:"username"`
+	IsActive       bool   `json:"is_active"`
+	MaxOpenReviews int    `json:"max_open_reviews,omitempty"`
+
+	// Tags — теги экспертизы участника (например, "postgres", "frontend").
+	Tags []string `json:"tags"`
+
+	// Level — уровень участника; по умолчанию mid.
+	Level Level `json:"level"`
 }
 
 // Team представляет команду и её участников.
@@ -15,12 +22,122 @@ type Team struct {
 	Members  []TeamMember `json:"members"`
 }
 
-// User представляет пользователя
-type User struct {
-	UserID   string `json:"user_id"`
-	Username string `json:"username"`
+// TeamSettings — настройки команды, хранящиеся в таблице teams.
+type TeamSettings struct {
 	TeamName string `json:"team_name"`
-	IsActive bool   `json:"is_active"`
+
+	// AutoReassignOnDeactivate — переназначать ли открытые ревью участника
+	// при его деактивации, если запрос не указал это явно.
+	AutoReassignOnDeactivate bool `json:"auto_reassign_on_deactivate"`
+
+	// MinApprovals — сколько ревьюверов должны одобрить PR автора
+	// из этой команды перед мерджем (0 — одобрения не требуются).
+	MinApprovals int `json:"min_approvals"`
+
+	// BlockOnChangesRequested — запрещать мердж, пока хотя бы один
+	// ревьювер находится в состоянии CHANGES_REQUESTED.
+	BlockOnChangesRequested bool `json:"block_on_changes_requested"`
+
+	// ReviewerCount — сколько ревьюверов автоматически назначается на PR
+	// автора из этой команды.
+	ReviewerCount int `json:"reviewer_count"`
+
+	// MinReviewers и MaxReviewers ограничивают ручное изменение списка
+	// ревьюверов (addReviewer/removeReviewer). MaxReviewers = 0 — без ограничения.
+	MinReviewers int `json:"min_reviewers"`
+	MaxReviewers int `json:"max_reviewers"`
+
+	// FallbackTeams — резервные команды в порядке обхода: из них добираются
+	// ревьюверы, если в этой команде кандидатов не хватает.
+	FallbackTeams []string `json:"fallback_teams"`
+
+	// RotationWindow — сколько последних PR автора учитывает стратегия
+	// rotation: недавние ревьюверы автора выбираются реже (0 — не учитывать).
+	RotationWindow int `json:"rotation_window"`
+
+	// RequireSenior — среди ревьюверов PR автора из этой команды должен быть
+	// хотя бы один старший (senior или lead).
+	RequireSenior bool `json:"require_senior"`
+}
+
+// Значения TeamSettings для новой команды.
+const (
+	DefaultReviewerCount  = 2
+	DefaultRotationWindow = 5
+)
+
+// NewTeamSettings возвращает настройки новой команды по умолчанию.
+func NewTeamSettings(name string) TeamSettings {
+	return TeamSettings{
+		TeamName:       name,
+		ReviewerCount:  DefaultReviewerCount,
+		FallbackTeams:  []string{},
+		RotationWindow: DefaultRotationWindow,
+	}
+}
+
+// User представляет пользователя.
+// MaxOpenReviews ограничивает число открытых ревью, 0 — без ограничения.
+type User struct {
+	UserID         string `json:"user_id"`
+	Username       string `json:"username"`
+	TeamName       string `json:"team_name"`
+	IsActive       bool   `json:"is_active"`
+	MaxOpenReviews int    `json:"max_open_reviews"`
+
+	// Tags — теги экспертизы: ревьюверы, чьи теги пересекаются
+	// с метками PR, назначаются в первую очередь.
+	Tags []string `json:"tags"`
+
+	Level Level `json:"level"`
+}
+
+// Level — уровень пользователя в команде.
+type Level string
+
+const (
+	LevelJunior Level = "junior"
+	LevelMid    Level = "mid"
+	LevelSenior Level = "senior"
+	LevelLead   Level = "lead"
+)
+
+// DefaultLevel — уровень участника, для которого он не указан.
+const DefaultLevel = LevelMid
+
+// Valid сообщает, является ли l известным уровнем.
+func (l Level) Valid() bool {
+	switch l {
+	case LevelJunior, LevelMid, LevelSenior, LevelLead:
+		return true
+	}
+	return false
+}
+
+// IsSenior сообщает, считается ли уровень старшим ревьювером.
+func (l Level) IsSenior() bool {
+	return l == LevelSenior || l == LevelLead
+}
+
+// UserUpdate описывает частичное обновление пользователя: nil-поля не меняются.
+type UserUpdate struct {
+	Username       *string `json:"username"`
+	MaxOpenReviews *int    `json:"max_open_reviews"`
+
+	// Tags заменяет теги пользователя целиком.
+	Tags *[]string `json:"tags"`
+
+	Level *Level `json:"level"`
+}
+
+// UnavailabilityWindow — интервал [StartsAt, EndsAt), в течение которого
+// пользователь не назначается ревьювером, даже если он активен.
+type UnavailabilityWindow struct {
+	ID       int64     `json:"window_id"`
+	UserID   string    `json:"user_id"`
+	StartsAt time.Time `json:"starts_at"`
+	EndsAt   time.Time `json:"ends_at"`
+	Reason   string    `json:"reason"`
 }
 
 type PullRequestStatus string
@@ -33,8 +150,25 @@ const (
 	// PRStatusMerged означает, что Pull Request был замёрджен.
 	// В этом состоянии изменение списка ревьюверов запрещено
 	PRStatusMerged PullRequestStatus = "MERGED"
+
+	// PRStatusDraft означает, что PR ещё не готов к ревью:
+	// ревьюверы назначаются только при переводе в OPEN.
+	PRStatusDraft PullRequestStatus = "DRAFT"
+
+	// PRStatusClosed означает, что PR закрыт без мерджа,
+	// а его ревьюверы освобождены. Закрытый PR можно переоткрыть.
+	PRStatusClosed PullRequestStatus = "CLOSED"
 )
 
+// Valid сообщает, является ли s известным статусом PR.
+func (s PullRequestStatus) Valid() bool {
+	switch s {
+	case PRStatusOpen, PRStatusMerged, PRStatusDraft, PRStatusClosed:
+		return true
+	}
+	return false
+}
+
 // PullRequest описывает сущность PR
 type PullRequest struct {
 	ID                string            `json:"pull_request_id"`
@@ -42,8 +176,208 @@ type PullRequest struct {
 	AuthorID          string            `json:"author_id"`
 	Status            PullRequestStatus `json:"status"`
 	AssignedReviewers []string          `json:"assigned_reviewers"`
+	Reviews           []ReviewerState   `json:"reviews"`
 	CreatedAt         *time.Time        `json:"createdAt,omitempty"`
 	MergedAt          *time.Time        `json:"mergedAt,omitempty"`
+	ClosedAt          *time.Time        `json:"closedAt,omitempty"`
+
+	// ChangedPaths — изменённые файлы PR, по ним выбираются владельцы кода.
+	ChangedPaths []string `json:"changed_paths,omitempty"`
+
+	// Labels — метки PR, по ним предпочитаются ревьюверы с совпадающими тегами.
+	Labels []string `json:"labels,omitempty"`
+
+	// MergeOverride — PR замёрджен в обход политики одобрений команды.
+	MergeOverride bool `json:"merge_override,omitempty"`
+
+	// Understaffed — при назначении нашлось меньше ревьюверов,
+	// чем reviewer_count команды автора. Заполняется сервисом.
+	Understaffed bool `json:"understaffed,omitempty"`
+}
+
+// NewPullRequest — параметры создания PR.
+type NewPullRequest struct {
+	ID       string `json:"pull_request_id"`
+	Name     string `json:"pull_request_name"`
+	AuthorID string `json:"author_id"`
+
+	// Draft — создать черновик без ревьюверов.
+	Draft bool `json:"draft"`
+
+	// ChangedPaths — изменённые файлы PR относительно корня репозитория.
+	ChangedPaths []string `json:"changed_paths"`
+
+	// Labels — метки PR (например, "postgres").
+	Labels []string `json:"labels"`
+}
+
+// OwnershipRule — строка CODEOWNERS: файлы по шаблону Pattern принадлежат Owners.
+type OwnershipRule struct {
+	Pattern string `json:"pattern"`
+
+	// Owners — владельцы в синтаксисе CODEOWNERS: @user_id или @org/team.
+	Owners []string `json:"owners"`
+}
+
+// ReviewState — решение ревьювера по PR.
+type ReviewState string
+
+const (
+	// ReviewPending — ревьювер назначен, но решения ещё не отправил.
+	ReviewPending ReviewState = "PENDING"
+
+	ReviewApproved         ReviewState = "APPROVED"
+	ReviewChangesRequested ReviewState = "CHANGES_REQUESTED"
+	ReviewCommented        ReviewState = "COMMENTED"
+)
+
+// IsDecision сообщает, может ли ревьювер отправить такое состояние.
+func (s ReviewState) IsDecision() bool {
+	switch s {
+	case ReviewApproved, ReviewChangesRequested, ReviewCommented:
+		return true
+	}
+	return false
+}
+
+// ReviewerState — текущее решение одного ревьювера по PR.
+type ReviewerState struct {
+	UserID      string      `json:"user_id"`
+	State       ReviewState `json:"state"`
+	AssignedAt  time.Time   `json:"assignedAt"`
+	SubmittedAt *time.Time  `json:"submittedAt,omitempty"`
+
+	ReviewerOrigin
+}
+
+// MatchReason — почему ревьювер назначен на PR.
+type MatchReason string
+
+const (
+	// MatchCodeowner — владелец изменённых файлов по CODEOWNERS.
+	MatchCodeowner MatchReason = "CODEOWNER"
+
+	// MatchTag — теги ревьювера пересекаются с метками PR.
+	MatchTag MatchReason = "TAG_MATCH"
+
+	// MatchTeam — выбран стратегией из команды автора (или команды заменяемого).
+	MatchTeam MatchReason = "TEAM"
+
+	// MatchFallbackTeam — взят из резервной команды.
+	MatchFallbackTeam MatchReason = "FALLBACK_TEAM"
+
+	// MatchSenior — добавлен, потому что команда автора требует старшего ревьювера.
+	MatchSenior MatchReason = "SENIOR"
+
+	// MatchManual — назначен вручную (addReviewer, reassign с new_user_id).
+	MatchManual MatchReason = "MANUAL"
+)
+
+// ReviewerOrigin — откуда и почему взят ревьювер.
+type ReviewerOrigin struct {
+	// MatchReason — причина назначения (пусто для назначенных до её появления).
+	MatchReason MatchReason `json:"match_reason,omitempty"`
+
+	// MatchedTags — теги ревьювера, совпавшие с метками PR (для TAG_MATCH).
+	MatchedTags []string `json:"matched_tags,omitempty"`
+
+	// FallbackTeam — резервная команда, из которой взят ревьювер
+	// (пусто, если он из основной команды или назначен вручную).
+	FallbackTeam string `json:"fallback_team,omitempty"`
+
+	// OwnerPattern — шаблон CODEOWNERS, по которому ревьювер назначен
+	// владельцем изменённых файлов.
+	OwnerPattern string `json:"owner_pattern,omitempty"`
+}
+
+// AssignmentKind — операция, при которой сервис выбирал ревьюверов PR.
+type AssignmentKind string
+
+const (
+	AssignmentCreate   AssignmentKind = "CREATE"
+	AssignmentReady    AssignmentKind = "READY"
+	AssignmentReopen   AssignmentKind = "REOPEN"
+	AssignmentReassign AssignmentKind = "REASSIGN"
+	AssignmentFill     AssignmentKind = "FILL"
+)
+
+/*
+Assignment — запись об автоматическом выборе ревьюверов PR: зерно
+случайности и стратегия, с которыми он выполнен, исключённые заранее
+пользователи, шаги выбора и итог. С тем же зерном и теми же кандидатами
+выбор повторяется, кроме шагов round_robin: позиция очереди хранится
+в памяти процесса и в запись не попадает, поэтому их не воспроизвести.
+*/
+type Assignment struct {
+	ID            int64          `json:"assignment_id"`
+	PullRequestID string         `json:"pull_request_id"`
+	Kind          AssignmentKind `json:"kind"`
+	Seed          int64          `json:"seed"`
+
+	// Strategy — стратегия шагов TEAM и FALLBACK_TEAM, если она у них общая;
+	// пусто, если таких шагов не было или их команды выбирали по-разному.
+	Strategy string `json:"strategy"`
+
+	// Excluded — не рассматривались: автор, уже назначенные, заменяемый.
+	Excluded []string         `json:"excluded"`
+	Steps    []AssignmentStep `json:"steps"`
+	Chosen   []string         `json:"chosen"`
+
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// AssignmentStep — один шаг выбора: среди кого выбирали и кого выбрали.
+type AssignmentStep struct {
+	Reason MatchReason `json:"reason"`
+	Team   string      `json:"team"`
+
+	// Strategy — стратегия команды (для шагов TEAM и FALLBACK_TEAM).
+	Strategy string `json:"strategy,omitempty"`
+
+	// OwnerPattern — правило CODEOWNERS (для шагов CODEOWNER).
+	OwnerPattern string `json:"owner_pattern,omitempty"`
+
+	// Pool — кандидаты шага, которые могли взять ревью; AtCapacity —
+	// подходившие, но исчерпавшие лимит открытых ревью.
+	Pool       []string `json:"pool"`
+	AtCapacity []string `json:"at_capacity"`
+	Chosen     []string `json:"chosen"`
+}
+
+/*
+ReviewerPreview — предлагаемые ревьюверы для ещё не созданного PR автора:
+как выбор при создании PR, но без сохранения. Strategy, Steps и Excluded
+имеют тот же смысл, что в Assignment.
+*/
+type ReviewerPreview struct {
+	AuthorID string           `json:"author_id"`
+	Seed     int64            `json:"seed"`
+	Strategy string           `json:"strategy"`
+	Excluded []string         `json:"excluded"`
+	Steps    []AssignmentStep `json:"steps"`
+
+	Reviewers []ProposedReviewer `json:"reviewers"`
+
+	// Understaffed — кандидатов меньше reviewer_count команды автора.
+	Understaffed bool `json:"understaffed"`
+}
+
+// ProposedReviewer — предлагаемый ревьювер и почему он выбран.
+type ProposedReviewer struct {
+	UserID string `json:"user_id"`
+	ReviewerOrigin
+}
+
+// ApprovalStatus — выполнение политики мерджа команды для конкретного PR.
+type ApprovalStatus struct {
+	RequiredApprovals int `json:"required_approvals"`
+	Approvals         int `json:"approvals"`
+
+	// MissingApprovals — назначенные ревьюверы, которые ещё не одобрили PR.
+	MissingApprovals []string `json:"missing_approvals"`
+
+	// ChangesRequested — ревьюверы, запросившие изменения.
+	ChangesRequested []string `json:"changes_requested"`
 }
 
 // PullRequestShort — сокращённое представление PR,
@@ -52,9 +386,127 @@ type PullRequestShort struct {
 	Name     string            `json:"pull_request_name"`
 	AuthorID string            `json:"author_id"`
 	Status   PullRequestStatus `json:"status"`
+
+	// CreatedAt и MergedAt заполняются в списке PR (/pullRequest/list).
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+	MergedAt  *time.Time `json:"mergedAt,omitempty"`
+}
+
+// PullRequestSort — порядок списка PR: поле и направление ("-" — по убыванию).
+type PullRequestSort string
+
+const (
+	SortCreatedAsc  PullRequestSort = "created_at"
+	SortCreatedDesc PullRequestSort = "-created_at"
+
+	// Сортировка по merged_at оставляет в списке только замёрдженные PR.
+	SortMergedAsc  PullRequestSort = "merged_at"
+	SortMergedDesc PullRequestSort = "-merged_at"
+)
+
+// DefaultPullRequestSort — порядок списка PR по умолчанию: сначала новые.
+const DefaultPullRequestSort = SortCreatedDesc
+
+// Valid сообщает, является ли s известным порядком.
+func (s PullRequestSort) Valid() bool {
+	switch s {
+	case SortCreatedAsc, SortCreatedDesc, SortMergedAsc, SortMergedDesc:
+		return true
+	}
+	return false
+}
+
+// ByMerged сообщает, упорядочен ли список по merged_at.
+func (s PullRequestSort) ByMerged() bool {
+	return s == SortMergedAsc || s == SortMergedDesc
+}
+
+// Desc сообщает, упорядочен ли список по убыванию.
+func (s PullRequestSort) Desc() bool {
+	return s == SortCreatedDesc || s == SortMergedDesc
+}
+
+/*
+PullRequestFilter — условия выборки списка PR. Пустые поля не ограничивают
+выборку, интервалы времени полуоткрытые: [From, To).
+*/
+type PullRequestFilter struct {
+	Statuses   []PullRequestStatus
+	AuthorID   string
+	ReviewerID string
+
+	// Team — команда автора PR.
+	Team string
+
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	MergedFrom  *time.Time
+	MergedTo    *time.Time
+
+	Sort PullRequestSort
+
+	// After — последний PR предыдущей страницы; nil — с начала списка.
+	After *PullRequestCursor
+
+	// Limit — сколько PR вернуть.
+	Limit int
+}
+
+// PullRequestCursor — позиция в списке PR: значение поля сортировки и id PR.
+type PullRequestCursor struct {
+	At time.Time
+	ID string
+}
+
+// PullRequestPage — страница списка PR.
+type PullRequestPage struct {
+	PullRequests []PullRequestShort `json:"pull_requests"`
+
+	// NextCursor передаётся в cursor за следующей страницей; пусто на последней.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// ReviewReassignment — ревью, переданное другому пользователю.
+type ReviewReassignment struct {
+	PullRequestID string `json:"pull_request_id"`
+	ReplacedBy    string `json:"replaced_by"`
+}
+
+// UnassignedReview — ревью, для которого не нашлось замены.
+// Reason содержит код ошибки (NO_CANDIDATE, AT_CAPACITY, SENIOR_REQUIRED).
+type UnassignedReview struct {
+	PullRequestID string `json:"pull_request_id"`
+	Reason        string `json:"reason"`
+}
+
+// ReviewerFill — ревьюверы, доназначенные на PR до reviewer_count команды автора.
+type ReviewerFill struct {
+	PullRequestID string   `json:"pull_request_id"`
+	Added         []string `json:"added"`
+}
+
+// ReassignReport — результат переназначения открытых ревью пользователя.
+type ReassignReport struct {
+	Reassigned  []ReviewReassignment `json:"reassigned"`
+	NoCandidate []UnassignedReview   `json:"no_candidate"`
 }
 
 type ReviewerStat struct {
-	UserID      string `json:"user_id"`
-	Assignments int    `json:"assignments"`
+	UserID          string `json:"user_id"`
+	Assignments     int    `json:"assignments"`
+	OpenAssignments int    `json:"open_assignments"`
+}
+
+// ReviewCandidate — активный участник команды, которого можно назначить ревьювером.
+type ReviewCandidate struct {
+	UserID         string
+	OpenReviews    int
+	MaxOpenReviews int
+	Tags           []string
+	Level          Level
+}
+
+// HasCapacity сообщает, может ли кандидат взять ещё одно ревью.
+func (c ReviewCandidate) HasCapacity() bool {
+	return c.MaxOpenReviews == 0 || c.OpenReviews < c.MaxOpenReviews
 }
@@ -0,0 +1,785 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"pr-review-service/internal/model"
+	"pr-review-service/internal/service"
+)
+
+var _ service.Repo = (*Repo)(nil)
+
+// errForeignKey соответствует нарушению внешнего ключа в PostgreSQL.
+var errForeignKey = errors.New("foreign key violation")
+
+// errDuplicateKey соответствует нарушению первичного ключа в PostgreSQL.
+var errDuplicateKey = errors.New("duplicate key")
+
+/*
+Repo — реализация service.Repo в памяти процесса для тестов и локального
+запуска без базы данных. Повторяет семантику PostgresRepo: ошибка
+"team_exists", sql.ErrNoRows для отсутствующих записей, проверка ссылок
+на пользователей.
+*/
+type Repo struct {
+	mu  sync.Mutex
+	st  state
+	now func() time.Time
+}
+
+func NewRepo() *Repo {
+	return &Repo{
+		st:  newState(),
+		now: time.Now,
+	}
+}
+
+// prRow — PR без ревьюверов; seq задаёт порядок создания.
+type prRow struct {
+	pr  model.PullRequest
+	seq int64
+}
+
+// state — все данные репозитория; копируется целиком для отката InTx.
+type state struct {
+	teams     map[string]model.TeamSettings
+	users     map[string]model.User
+	prs       map[string]prRow
+	reviewers map[string][]model.ReviewerState
+	windows   map[int64]model.UnavailabilityWindow
+
+	codeowners  map[string][]model.OwnershipRule
+	assignments []model.Assignment
+
+	nextWindowID     int64
+	nextSeq          int64
+	nextAssignmentID int64
+}
+
+func newState() state {
+	return state{
+		teams:     map[string]model.TeamSettings{},
+		users:     map[string]model.User{},
+		prs:       map[string]prRow{},
+		reviewers: map[string][]model.ReviewerState{},
+		windows:   map[int64]model.UnavailabilityWindow{},
+
+		codeowners: map[string][]model.OwnershipRule{},
+	}
+}
+
+func (s state) clone() state {
+	c := newState()
+	for k, v := range s.teams {
+		c.teams[k] = v
+	}
+	for k, v := range s.users {
+		c.users[k] = v
+	}
+	for k, v := range s.prs {
+		c.prs[k] = v
+	}
+	for k, v := range s.reviewers {
+		c.reviewers[k] = append([]model.ReviewerState(nil), v...)
+	}
+	for k, v := range s.windows {
+		c.windows[k] = v
+	}
+	for k, v := range s.codeowners {
+		c.codeowners[k] = v
+	}
+	c.assignments = append([]model.Assignment(nil), s.assignments...)
+	c.nextWindowID = s.nextWindowID
+	c.nextSeq = s.nextSeq
+	c.nextAssignmentID = s.nextAssignmentID
+	return c
+}
+
+// txKey — ключ контекста, которым InTx помечает, что блокировка уже захвачена.
+type txKey struct{}
+
+// lock захватывает мьютекс, если вызов не находится внутри InTx этого репозитория.
+func (r *Repo) lock(ctx context.Context) func() {
+	if ctx.Value(txKey{}) == r {
+		return func() {}
+	}
+	r.mu.Lock()
+	return r.mu.Unlock
+}
+
+/*
+InTx выполняет fn под единой блокировкой репозитория. Если fn возвращает
+ошибку, все сделанные внутри изменения откатываются.
+*/
+func (r *Repo) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if ctx.Value(txKey{}) == r {
+		return fn(ctx)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	snapshot := r.st.clone()
+	if err := fn(context.WithValue(ctx, txKey{}, r)); err != nil {
+		r.st = snapshot
+		return err
+	}
+	return nil
+}
+
+func (r *Repo) CreateTeamWithMembers(ctx context.Context, t model.Team) error {
+	defer r.lock(ctx)()
+
+	if _, ok := r.st.teams[t.TeamName]; ok {
+		return errors.New("team_exists")
+	}
+
+	r.st.teams[t.TeamName] = model.NewTeamSettings(t.TeamName)
+	for _, m := range t.Members {
+		u := model.User{
+			UserID:         m.UserID,
+			Username:       m.Username,
+			TeamName:       t.TeamName,
+			IsActive:       m.IsActive,
+			MaxOpenReviews: m.MaxOpenReviews,
+			Tags:           append([]string{}, m.Tags...),
+			Level:          m.Level,
+		}
+		if u.Level == "" {
+			u.Level = model.DefaultLevel
+		}
+		r.st.users[m.UserID] = u
+	}
+	return nil
+}
+
+func (r *Repo) GetTeam(ctx context.Context, name string) (*model.Team, error) {
+	defer r.lock(ctx)()
+
+	if _, ok := r.st.teams[name]; !ok {
+		return nil, sql.ErrNoRows
+	}
+
+	team := model.Team{TeamName: name, Members: []model.TeamMember{}}
+	for _, u := range r.st.sortedUsers() {
+		if u.TeamName != name {
+			continue
+		}
+		team.Members = append(team.Members, model.TeamMember{
+			UserID:         u.UserID,
+			Username:       u.Username,
+			IsActive:       u.IsActive,
+			MaxOpenReviews: u.MaxOpenReviews,
+			Tags:           append([]string{}, u.Tags...),
+			Level:          u.Level,
+		})
+	}
+	return &team, nil
+}
+
+func (r *Repo) GetTeamSettings(ctx context.Context, name string) (*model.TeamSettings, error) {
+	defer r.lock(ctx)()
+
+	ts, ok := r.st.teams[name]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	ts.FallbackTeams = append([]string{}, ts.FallbackTeams...)
+	return &ts, nil
+}
+
+func (r *Repo) UpdateTeamSettings(ctx context.Context, ts model.TeamSettings) error {
+	defer r.lock(ctx)()
+
+	if _, ok := r.st.teams[ts.TeamName]; !ok {
+		return sql.ErrNoRows
+	}
+	ts.FallbackTeams = append([]string{}, ts.FallbackTeams...)
+	r.st.teams[ts.TeamName] = ts
+	return nil
+}
+
+func (r *Repo) GetOwnershipRules(ctx context.Context, team string) ([]model.OwnershipRule, error) {
+	defer r.lock(ctx)()
+
+	return append([]model.OwnershipRule{}, r.st.codeowners[team]...), nil
+}
+
+func (r *Repo) SetOwnershipRules(ctx context.Context, team string, rules []model.OwnershipRule) error {
+	defer r.lock(ctx)()
+
+	if _, ok := r.st.teams[team]; !ok {
+		return sql.ErrNoRows
+	}
+	r.st.codeowners[team] = append([]model.OwnershipRule{}, rules...)
+	return nil
+}
+
+func (r *Repo) GetUserByID(ctx context.Context, id string) (*model.User, error) {
+	defer r.lock(ctx)()
+
+	u, ok := r.st.users[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	u.Tags = append([]string{}, u.Tags...)
+	return &u, nil
+}
+
+func (r *Repo) UpdateUserIsActive(ctx context.Context, id string, active bool) (*model.User, error) {
+	defer r.lock(ctx)()
+
+	u, ok := r.st.users[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	u.IsActive = active
+	r.st.users[id] = u
+	u.Tags = append([]string{}, u.Tags...)
+	return &u, nil
+}
+
+func (r *Repo) UpdateUser(ctx context.Context, id string, upd model.UserUpdate) (*model.User, error) {
+	defer r.lock(ctx)()
+
+	u, ok := r.st.users[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	if upd.Username != nil {
+		u.Username = *upd.Username
+	}
+	if upd.MaxOpenReviews != nil {
+		u.MaxOpenReviews = *upd.MaxOpenReviews
+	}
+	if upd.Tags != nil {
+		u.Tags = append([]string{}, *upd.Tags...)
+	}
+	if upd.Level != nil {
+		u.Level = *upd.Level
+	}
+	r.st.users[id] = u
+	u.Tags = append([]string{}, u.Tags...)
+	return &u, nil
+}
+
+func (r *Repo) CreateUnavailability(ctx context.Context, w model.UnavailabilityWindow) (*model.UnavailabilityWindow, error) {
+	defer r.lock(ctx)()
+
+	if _, ok := r.st.users[w.UserID]; !ok {
+		return nil, errForeignKey
+	}
+	r.st.nextWindowID++
+	w.ID = r.st.nextWindowID
+	r.st.windows[w.ID] = w
+	return &w, nil
+}
+
+func (r *Repo) ListUnavailability(ctx context.Context, uid string) ([]model.UnavailabilityWindow, error) {
+	defer r.lock(ctx)()
+
+	now := r.now()
+	result := []model.UnavailabilityWindow{}
+	for _, w := range r.st.windows {
+		if w.UserID == uid && w.EndsAt.After(now) {
+			result = append(result, w)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].StartsAt.Equal(result[j].StartsAt) {
+			return result[i].StartsAt.Before(result[j].StartsAt)
+		}
+		return result[i].ID < result[j].ID
+	})
+	return result, nil
+}
+
+func (r *Repo) DeleteUnavailability(ctx context.Context, id int64) error {
+	defer r.lock(ctx)()
+
+	if _, ok := r.st.windows[id]; !ok {
+		return sql.ErrNoRows
+	}
+	delete(r.st.windows, id)
+	return nil
+}
+
+func (r *Repo) PRExists(ctx context.Context, id string) (bool, error) {
+	defer r.lock(ctx)()
+
+	_, ok := r.st.prs[id]
+	return ok, nil
+}
+
+func (r *Repo) CreatePullRequest(ctx context.Context, pr model.PullRequest) error {
+	defer r.lock(ctx)()
+
+	if _, ok := r.st.prs[pr.ID]; ok {
+		return errDuplicateKey
+	}
+	if _, ok := r.st.users[pr.AuthorID]; !ok {
+		return errForeignKey
+	}
+	if err := r.st.checkUsers(pr.AssignedReviewers); err != nil {
+		return err
+	}
+
+	row := model.PullRequest{
+		ID:       pr.ID,
+		Name:     pr.Name,
+		AuthorID: pr.AuthorID,
+		Status:   pr.Status,
+
+		ChangedPaths: append([]string{}, pr.ChangedPaths...),
+		Labels:       append([]string{}, pr.Labels...),
+	}
+	if row.Status == "" {
+		row.Status = model.PRStatusOpen
+	}
+	created := r.now().UTC()
+	if pr.CreatedAt != nil {
+		created = *pr.CreatedAt
+	}
+	row.CreatedAt = &created
+
+	r.st.nextSeq++
+	r.st.prs[pr.ID] = prRow{pr: row, seq: r.st.nextSeq}
+	r.st.reviewers[pr.ID] = nil
+	for _, uid := range pr.AssignedReviewers {
+		r.st.reviewers[pr.ID] = append(r.st.reviewers[pr.ID], model.ReviewerState{
+			UserID:     uid,
+			State:      model.ReviewPending,
+			AssignedAt: created,
+		})
+	}
+	return nil
+}
+
+func (r *Repo) GetPullRequestWithReviewers(ctx context.Context, id string) (*model.PullRequest, error) {
+	defer r.lock(ctx)()
+
+	return r.st.pullRequest(id)
+}
+
+// LockPullRequest только проверяет PR: InTx и так держит блокировку репозитория.
+func (r *Repo) LockPullRequest(ctx context.Context, id string) error {
+	defer r.lock(ctx)()
+
+	if _, ok := r.st.prs[id]; !ok {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (r *Repo) SetPRMerged(ctx context.Context, id string, mergedAt sql.NullTime, override bool) (*model.PullRequest, error) {
+	defer r.lock(ctx)()
+
+	row, ok := r.st.prs[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	row.pr.Status = model.PRStatusMerged
+	row.pr.MergeOverride = override
+	row.pr.MergedAt = nil
+	if mergedAt.Valid {
+		t := mergedAt.Time
+		row.pr.MergedAt = &t
+	}
+	r.st.prs[id] = row
+
+	return r.st.pullRequest(id)
+}
+
+func (r *Repo) SetPRStatus(ctx context.Context, id string, status model.PullRequestStatus, at time.Time) error {
+	defer r.lock(ctx)()
+
+	row, ok := r.st.prs[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	row.pr.Status = status
+	row.pr.ClosedAt = nil
+	if status == model.PRStatusClosed {
+		row.pr.ClosedAt = &at
+	}
+	r.st.prs[id] = row
+	return nil
+}
+
+func (r *Repo) SetPRReviewers(ctx context.Context, id string, reviewers []string) error {
+	defer r.lock(ctx)()
+
+	if err := r.st.checkUsers(reviewers); err != nil {
+		return err
+	}
+	if _, ok := r.st.prs[id]; !ok {
+		if len(reviewers) > 0 {
+			return errForeignKey
+		}
+		return nil
+	}
+
+	kept := map[string]model.ReviewerState{}
+	for _, rs := range r.st.reviewers[id] {
+		kept[rs.UserID] = rs
+	}
+
+	now := r.now().UTC()
+	next := []model.ReviewerState{}
+	seen := map[string]bool{}
+	for _, uid := range reviewers {
+		if seen[uid] {
+			continue
+		}
+		seen[uid] = true
+		if rs, ok := kept[uid]; ok {
+			next = append(next, rs)
+			continue
+		}
+		next = append(next, model.ReviewerState{UserID: uid, State: model.ReviewPending, AssignedAt: now})
+	}
+
+	r.st.reviewers[id] = next
+	return nil
+}
+
+func (r *Repo) SetReviewState(ctx context.Context, prID, userID string, state model.ReviewState, at time.Time) error {
+	defer r.lock(ctx)()
+
+	for i, rs := range r.st.reviewers[prID] {
+		if rs.UserID == userID {
+			rs.State = state
+			rs.SubmittedAt = &at
+			r.st.reviewers[prID][i] = rs
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
+
+func (r *Repo) SetReviewerOrigin(ctx context.Context, prID, userID string, o model.ReviewerOrigin) error {
+	defer r.lock(ctx)()
+
+	for i, rs := range r.st.reviewers[prID] {
+		if rs.UserID == userID {
+			r.st.reviewers[prID][i].ReviewerOrigin = o
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
+
+func (r *Repo) GetReviewCandidates(ctx context.Context, team string, exclude []string) ([]model.ReviewCandidate, error) {
+	defer r.lock(ctx)()
+
+	return r.st.candidates(team, exclude, r.now()), nil
+}
+
+func (r *Repo) GetPullRequestsByReviewer(ctx context.Context, uid string) ([]model.PullRequestShort, error) {
+	defer r.lock(ctx)()
+
+	result := []model.PullRequestShort{}
+	for _, row := range r.st.sortedPRs() {
+		for _, rs := range r.st.reviewers[row.pr.ID] {
+			if rs.UserID == uid {
+				result = append(result, model.PullRequestShort{
+					ID:       row.pr.ID,
+					Name:     row.pr.Name,
+					AuthorID: row.pr.AuthorID,
+					Status:   row.pr.Status,
+				})
+				break
+			}
+		}
+	}
+	return result, nil
+}
+
+func (r *Repo) ListPullRequests(ctx context.Context, f model.PullRequestFilter) ([]model.PullRequestShort, error) {
+	defer r.lock(ctx)()
+
+	// key — значение поля сортировки; nil исключает PR из списка по merged_at.
+	key := func(pr model.PullRequest) *time.Time {
+		if f.Sort.ByMerged() {
+			return pr.MergedAt
+		}
+		return pr.CreatedAt
+	}
+	// before сообщает, идёт ли позиция (at, id) раньше (bAt, bID) в порядке f.Sort.
+	before := func(at time.Time, id string, bAt time.Time, bID string) bool {
+		if f.Sort.Desc() {
+			return at.After(bAt) || at.Equal(bAt) && id > bID
+		}
+		return at.Before(bAt) || at.Equal(bAt) && id < bID
+	}
+
+	prs := []model.PullRequest{}
+	for _, row := range r.st.prs {
+		k := key(row.pr)
+		if k == nil || !r.st.listed(row.pr, f) {
+			continue
+		}
+		if f.After != nil && !before(f.After.At, f.After.ID, *k, row.pr.ID) {
+			continue
+		}
+		prs = append(prs, row.pr)
+	}
+	sort.Slice(prs, func(i, j int) bool {
+		return before(*key(prs[i]), prs[i].ID, *key(prs[j]), prs[j].ID)
+	})
+	if len(prs) > f.Limit {
+		prs = prs[:f.Limit]
+	}
+
+	result := []model.PullRequestShort{}
+	for _, pr := range prs {
+		result = append(result, model.PullRequestShort{
+			ID:        pr.ID,
+			Name:      pr.Name,
+			AuthorID:  pr.AuthorID,
+			Status:    pr.Status,
+			CreatedAt: pr.CreatedAt,
+			MergedAt:  pr.MergedAt,
+		})
+	}
+	return result, nil
+}
+
+func (r *Repo) GetUnderstaffedPullRequests(ctx context.Context, team string, want int) ([]string, error) {
+	defer r.lock(ctx)()
+
+	result := []string{}
+	for _, row := range r.st.sortedPRs() {
+		if row.pr.Status != model.PRStatusOpen || r.st.users[row.pr.AuthorID].TeamName != team {
+			continue
+		}
+		if len(r.st.reviewers[row.pr.ID]) < want {
+			result = append(result, row.pr.ID)
+		}
+	}
+	return result, nil
+}
+
+func (r *Repo) GetRecentReviewers(ctx context.Context, author string, n int) ([][]string, error) {
+	defer r.lock(ctx)()
+
+	prs := r.st.sortedPRs()
+	sort.SliceStable(prs, func(i, j int) bool {
+		a, b := prs[i].pr, prs[j].pr
+		if !a.CreatedAt.Equal(*b.CreatedAt) {
+			return a.CreatedAt.After(*b.CreatedAt)
+		}
+		return a.ID > b.ID
+	})
+
+	result := [][]string{}
+	for _, row := range prs {
+		if len(result) >= n {
+			break
+		}
+		revs := r.st.reviewers[row.pr.ID]
+		if row.pr.AuthorID != author || len(revs) == 0 {
+			continue
+		}
+		ids := []string{}
+		for _, rs := range revs {
+			ids = append(ids, rs.UserID)
+		}
+		sort.Strings(ids)
+		result = append(result, ids)
+	}
+	return result, nil
+}
+
+func (r *Repo) CreateAssignment(ctx context.Context, a model.Assignment) (*model.Assignment, error) {
+	defer r.lock(ctx)()
+
+	if _, ok := r.st.prs[a.PullRequestID]; !ok {
+		return nil, errForeignKey
+	}
+	r.st.nextAssignmentID++
+	a.ID = r.st.nextAssignmentID
+	r.st.assignments = append(r.st.assignments, a)
+	return &a, nil
+}
+
+func (r *Repo) ListAssignments(ctx context.Context, prID string) ([]model.Assignment, error) {
+	defer r.lock(ctx)()
+
+	result := []model.Assignment{}
+	for _, a := range r.st.assignments {
+		if a.PullRequestID == prID {
+			result = append(result, a)
+		}
+	}
+	return result, nil
+}
+
+func (r *Repo) GetReviewerAssignmentStats(ctx context.Context) ([]model.ReviewerStat, error) {
+	defer r.lock(ctx)()
+
+	byUser := map[string]*model.ReviewerStat{}
+	for prID, revs := range r.st.reviewers {
+		open := r.st.prs[prID].pr.Status == model.PRStatusOpen
+		for _, rs := range revs {
+			st, ok := byUser[rs.UserID]
+			if !ok {
+				st = &model.ReviewerStat{UserID: rs.UserID}
+				byUser[rs.UserID] = st
+			}
+			st.Assignments++
+			if open {
+				st.OpenAssignments++
+			}
+		}
+	}
+
+	var stats []model.ReviewerStat
+	for _, st := range byUser {
+		stats = append(stats, *st)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Assignments != stats[j].Assignments {
+			return stats[i].Assignments > stats[j].Assignments
+		}
+		return stats[i].UserID < stats[j].UserID
+	})
+	return stats, nil
+}
+
+// pullRequest собирает PR вместе с ревьюверами в порядке assigned_at, user_id.
+func (s state) pullRequest(id string) (*model.PullRequest, error) {
+	row, ok := s.prs[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+
+	pr := row.pr
+	pr.ChangedPaths = append([]string{}, row.pr.ChangedPaths...)
+	pr.Labels = append([]string{}, row.pr.Labels...)
+	pr.AssignedReviewers = []string{}
+	pr.Reviews = []model.ReviewerState{}
+
+	revs := append([]model.ReviewerState{}, s.reviewers[id]...)
+	sort.Slice(revs, func(i, j int) bool {
+		if !revs[i].AssignedAt.Equal(revs[j].AssignedAt) {
+			return revs[i].AssignedAt.Before(revs[j].AssignedAt)
+		}
+		return revs[i].UserID < revs[j].UserID
+	})
+	for _, rs := range revs {
+		pr.AssignedReviewers = append(pr.AssignedReviewers, rs.UserID)
+		pr.Reviews = append(pr.Reviews, rs)
+	}
+	return &pr, nil
+}
+
+// candidates повторяет GetReviewCandidates из PostgresRepo.
+func (s state) candidates(team string, exclude []string, now time.Time) []model.ReviewCandidate {
+	excluded := map[string]bool{}
+	for _, uid := range exclude {
+		excluded[uid] = true
+	}
+
+	open := s.openReviews()
+	result := []model.ReviewCandidate{}
+	for _, u := range s.sortedUsers() {
+		if u.TeamName != team || !u.IsActive || excluded[u.UserID] || s.unavailable(u.UserID, now) {
+			continue
+		}
+		result = append(result, model.ReviewCandidate{
+			UserID:         u.UserID,
+			OpenReviews:    open[u.UserID],
+			MaxOpenReviews: u.MaxOpenReviews,
+			Tags:           append([]string{}, u.Tags...),
+			Level:          u.Level,
+		})
+	}
+	return result
+}
+
+// openReviews считает ревью каждого пользователя в открытых PR.
+func (s state) openReviews() map[string]int {
+	result := map[string]int{}
+	for prID, revs := range s.reviewers {
+		if s.prs[prID].pr.Status != model.PRStatusOpen {
+			continue
+		}
+		for _, rs := range revs {
+			result[rs.UserID]++
+		}
+	}
+	return result
+}
+
+// listed сообщает, подходит ли PR под условия f без учёта курсора.
+func (s state) listed(pr model.PullRequest, f model.PullRequestFilter) bool {
+	if len(f.Statuses) > 0 && !slices.Contains(f.Statuses, pr.Status) {
+		return false
+	}
+	if f.AuthorID != "" && pr.AuthorID != f.AuthorID {
+		return false
+	}
+	if f.Team != "" && s.users[pr.AuthorID].TeamName != f.Team {
+		return false
+	}
+	if f.ReviewerID != "" && !slices.ContainsFunc(s.reviewers[pr.ID], func(rs model.ReviewerState) bool {
+		return rs.UserID == f.ReviewerID
+	}) {
+		return false
+	}
+	return within(pr.CreatedAt, f.CreatedFrom, f.CreatedTo) && within(pr.MergedAt, f.MergedFrom, f.MergedTo)
+}
+
+// within сообщает, попадает ли t в [from, to); nil-граница не ограничивает.
+func within(t, from, to *time.Time) bool {
+	if from == nil && to == nil {
+		return true
+	}
+	if t == nil {
+		return false
+	}
+	return (from == nil || !t.Before(*from)) && (to == nil || t.Before(*to))
+}
+
+func (s state) unavailable(uid string, now time.Time) bool {
+	for _, w := range s.windows {
+		if w.UserID == uid && !now.Before(w.StartsAt) && now.Before(w.EndsAt) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s state) checkUsers(ids []string) error {
+	for _, uid := range ids {
+		if _, ok := s.users[uid]; !ok {
+			return errForeignKey
+		}
+	}
+	return nil
+}
+
+func (s state) sortedUsers() []model.User {
+	result := make([]model.User, 0, len(s.users))
+	for _, u := range s.users {
+		result = append(result, u)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].UserID < result[j].UserID })
+	return result
+}
+
+func (s state) sortedPRs() []prRow {
+	result := make([]prRow, 0, len(s.prs))
+	for _, row := range s.prs {
+		result = append(result, row)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].seq < result[j].seq })
+	return result
+}
@@ -3,8 +3,14 @@ package repo
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
 
 	"pr-review-service/internal/model"
 )
@@ -17,11 +23,11 @@ PostgresRepo реализует слой доступа к данным (Reposit
 удобный интерфейс для сервисного слоя, скрывая детали SQL.
 */
 type PostgresRepo struct {
-	db *sql.DB
+	sqlConn
 }
 
 func NewPostgresRepo(db *sql.DB) *PostgresRepo {
-	return &PostgresRepo{db: db}
+	return &PostgresRepo{sqlConn{db: db}}
 }
 
 /*
@@ -29,7 +35,7 @@ CreateTeamWithMembers создаёт команду и всех её участ
 в рамках одной транзакции.
 */
 func (r *PostgresRepo) CreateTeamWithMembers(ctx context.Context, t model.Team) error {
-	tx, err := r.db.BeginTx(ctx, nil)
+	tx, err := r.beginTx(ctx)
 	if err != nil {
 		return err
 	}
@@ -58,13 +64,16 @@ func (r *PostgresRepo) CreateTeamWithMembers(ctx context.Context, t model.Team)
 
 	for _, m := range t.Members {
 		_, err = tx.ExecContext(ctx, `
-			INSERT INTO users(user_id, username, team_name, is_active)
-			VALUES ($1, $2, $3, $4)
+			INSERT INTO users(user_id, username, team_name, is_active, max_open_reviews, tags, level)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
 			ON CONFLICT (user_id) DO UPDATE
 				SET username = EXCLUDED.username,
 					team_name = EXCLUDED.team_name,
-					is_active = EXCLUDED.is_active
-		`, m.UserID, m.Username, t.TeamName, m.IsActive)
+					is_active = EXCLUDED.is_active,
+					max_open_reviews = EXCLUDED.max_open_reviews,
+					tags = EXCLUDED.tags,
+					level = EXCLUDED.level
+		`, m.UserID, m.Username, t.TeamName, m.IsActive, m.MaxOpenReviews, strings.Join(m.Tags, " "), levelArg(m.Level))
 		if err != nil {
 			return err
 		}
@@ -77,8 +86,8 @@ func (r *PostgresRepo) CreateTeamWithMembers(ctx context.Context, t model.Team)
 GetTeam возвращает команду и всех её участников.
 */
 func (r *PostgresRepo) GetTeam(ctx context.Context, name string) (*model.Team, error) {
-	rows, err := r.db.QueryContext(ctx, `
-		SELECT t.name, u.user_id, u.username, u.is_active
+	rows, err := r.conn(ctx).QueryContext(ctx, `
+		SELECT t.name, u.user_id, u.username, u.is_active, u.max_open_reviews, u.tags, u.level
 		FROM teams t
 		LEFT JOIN users u ON u.team_name = t.name
 		WHERE t.name=$1
@@ -94,10 +103,11 @@ func (r *PostgresRepo) GetTeam(ctx context.Context, name string) (*model.Team, e
 
 	for rows.Next() {
 		found = true
-		var tn, uid, uname sql.NullString
+		var tn, uid, uname, tags, level sql.NullString
 		var act sql.NullBool
+		var maxOpen sql.NullInt64
 
-		if err := rows.Scan(&tn, &uid, &uname, &act); err != nil {
+		if err := rows.Scan(&tn, &uid, &uname, &act, &maxOpen, &tags, &level); err != nil {
 			return nil, err
 		}
 
@@ -105,9 +115,12 @@ func (r *PostgresRepo) GetTeam(ctx context.Context, name string) (*model.Team, e
 
 		if uid.Valid {
 			members = append(members, model.TeamMember{
-				UserID:   uid.String,
-				Username: uname.String,
-				IsActive: act.Bool,
+				UserID:         uid.String,
+				Username:       uname.String,
+				IsActive:       act.Bool,
+				MaxOpenReviews: int(maxOpen.Int64),
+				Tags:           strings.Fields(tags.String),
+				Level:          model.Level(level.String),
 			})
 		}
 	}
@@ -124,37 +137,243 @@ func (r *PostgresRepo) GetTeam(ctx context.Context, name string) (*model.Team, e
 	return &team, nil
 }
 
+/*
+GetTeamSettings возвращает настройки команды вместе с резервными командами.
+Возвращает sql.ErrNoRows, если команды нет.
+*/
+func (r *PostgresRepo) GetTeamSettings(ctx context.Context, name string) (*model.TeamSettings, error) {
+	row := r.conn(ctx).QueryRowContext(ctx, `
+		SELECT `+teamSettingsColumns+`
+		FROM teams
+		WHERE name=$1
+	`, name)
+
+	ts, err := scanTeamSettings(row)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.conn(ctx).QueryContext(ctx, `
+		SELECT fallback_team
+		FROM team_fallbacks
+		WHERE team_name=$1
+		ORDER BY position
+	`, name)
+	if err != nil {
+		return nil, err
+	}
+	ts.FallbackTeams, err = scanIDs(rows)
+	if err != nil {
+		return nil, err
+	}
+	return ts, nil
+}
+
+/*
+UpdateTeamSettings сохраняет настройки команды и заменяет список
+резервных команд. Возвращает sql.ErrNoRows, если команды нет.
+*/
+func (r *PostgresRepo) UpdateTeamSettings(ctx context.Context, ts model.TeamSettings) error {
+	tx, err := r.beginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.ExecContext(ctx, `
+		UPDATE teams
+		SET auto_reassign_on_deactivate=$2, min_approvals=$3, block_on_changes_requested=$4,
+			reviewer_count=$5, min_reviewers=$6, max_reviewers=$7, rotation_window=$8, require_senior=$9
+		WHERE name=$1
+	`, append([]interface{}{ts.TeamName}, teamSettingsArgs(ts)...)...)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM team_fallbacks WHERE team_name=$1`, ts.TeamName); err != nil {
+		return err
+	}
+	for i, fb := range ts.FallbackTeams {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO team_fallbacks(team_name, fallback_team, position)
+			VALUES ($1, $2, $3)
+		`, ts.TeamName, fb, i)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+/*
+GetOwnershipRules возвращает правила CODEOWNERS команды в порядке объявления.
+Для команды без правил возвращается пустой список.
+*/
+func (r *PostgresRepo) GetOwnershipRules(ctx context.Context, team string) ([]model.OwnershipRule, error) {
+	rows, err := r.conn(ctx).QueryContext(ctx, `
+		SELECT pattern, owners
+		FROM team_codeowners
+		WHERE team_name=$1
+		ORDER BY position
+	`, team)
+	if err != nil {
+		return nil, err
+	}
+	return scanOwnershipRules(rows)
+}
+
+/*
+SetOwnershipRules заменяет правила CODEOWNERS команды.
+Возвращает sql.ErrNoRows, если команды нет.
+*/
+func (r *PostgresRepo) SetOwnershipRules(ctx context.Context, team string, rules []model.OwnershipRule) error {
+	tx, err := r.beginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var exists bool
+	err = tx.QueryRowContext(ctx,
+		"SELECT EXISTS (SELECT 1 FROM teams WHERE name=$1)", team,
+	).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return sql.ErrNoRows
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM team_codeowners WHERE team_name=$1`, team); err != nil {
+		return err
+	}
+	for i, rule := range rules {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO team_codeowners(team_name, position, pattern, owners)
+			VALUES ($1, $2, $3, $4)
+		`, team, i, rule.Pattern, strings.Join(rule.Owners, " "))
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
 /*
 GetUserByID возвращает пользователя по идентификатору.
 */
 func (r *PostgresRepo) GetUserByID(ctx context.Context, id string) (*model.User, error) {
-	row := r.db.QueryRowContext(ctx, `
-		SELECT user_id, username, team_name, is_active
+	row := r.conn(ctx).QueryRowContext(ctx, `
+		SELECT `+userColumns+`
 		FROM users
 		WHERE user_id=$1
 	`, id)
-
-	var u model.User
-	if err := row.Scan(&u.UserID, &u.Username, &u.TeamName, &u.IsActive); err != nil {
-		return nil, err
-	}
-	return &u, nil
+	return scanUser(row)
 }
 
 /*
 UpdateUserIsActive обновляет флаг активности пользователя.
 */
 func (r *PostgresRepo) UpdateUserIsActive(ctx context.Context, id string, active bool) (*model.User, error) {
-	row := r.db.QueryRowContext(ctx, `
+	row := r.conn(ctx).QueryRowContext(ctx, `
 		UPDATE users SET is_active=$1 WHERE user_id=$2
-		RETURNING user_id, username, team_name, is_active
+		RETURNING `+userColumns+`
 	`, active, id)
+	return scanUser(row)
+}
 
-	var u model.User
-	if err := row.Scan(&u.UserID, &u.Username, &u.TeamName, &u.IsActive); err != nil {
+/*
+UpdateUser частично обновляет пользователя: поля со значением nil не меняются.
+*/
+func (r *PostgresRepo) UpdateUser(ctx context.Context, id string, upd model.UserUpdate) (*model.User, error) {
+	row := r.conn(ctx).QueryRowContext(ctx, `
+		UPDATE users
+		SET username = COALESCE($2, username),
+			max_open_reviews = COALESCE($3, max_open_reviews),
+			tags = COALESCE($4, tags),
+			level = COALESCE($5, level)
+		WHERE user_id=$1
+		RETURNING `+userColumns+`
+	`, id, upd.Username, upd.MaxOpenReviews, tagsArg(upd.Tags), upd.Level)
+	return scanUser(row)
+}
+
+/*
+CreateUnavailability сохраняет окно недоступности пользователя.
+*/
+func (r *PostgresRepo) CreateUnavailability(ctx context.Context, w model.UnavailabilityWindow) (*model.UnavailabilityWindow, error) {
+	err := r.conn(ctx).QueryRowContext(ctx, `
+		INSERT INTO user_unavailability(user_id, starts_at, ends_at, reason)
+		VALUES ($1, $2, $3, $4)
+		RETURNING window_id
+	`, w.UserID, w.StartsAt, w.EndsAt, w.Reason).Scan(&w.ID)
+	if err != nil {
 		return nil, err
 	}
-	return &u, nil
+	return &w, nil
+}
+
+/*
+ListUnavailability возвращает окна недоступности пользователя,
+которые ещё не закончились, в порядке начала.
+*/
+func (r *PostgresRepo) ListUnavailability(ctx context.Context, uid string) ([]model.UnavailabilityWindow, error) {
+	rows, err := r.conn(ctx).QueryContext(ctx, `
+		SELECT window_id, user_id, starts_at, ends_at, reason
+		FROM user_unavailability
+		WHERE user_id=$1 AND ends_at > now()
+		ORDER BY starts_at, window_id
+	`, uid)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	result := []model.UnavailabilityWindow{}
+	for rows.Next() {
+		var w model.UnavailabilityWindow
+		if err := rows.Scan(&w.ID, &w.UserID, &w.StartsAt, &w.EndsAt, &w.Reason); err != nil {
+			return nil, err
+		}
+		result = append(result, w)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+/*
+DeleteUnavailability удаляет окно недоступности.
+Возвращает sql.ErrNoRows, если окна с таким ID нет.
+*/
+func (r *PostgresRepo) DeleteUnavailability(ctx context.Context, id int64) error {
+	res, err := r.conn(ctx).ExecContext(ctx,
+		`DELETE FROM user_unavailability WHERE window_id=$1`, id)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
 }
 
 /*
@@ -163,7 +382,7 @@ PRExists проверяет, существует ли Pull Request с указ
 */
 func (r *PostgresRepo) PRExists(ctx context.Context, id string) (bool, error) {
 	var exists bool
-	err := r.db.QueryRowContext(ctx,
+	err := r.conn(ctx).QueryRowContext(ctx,
 		"SELECT EXISTS (SELECT 1 FROM pull_requests WHERE pull_request_id=$1)", id,
 	).Scan(&exists)
 	return exists, err
@@ -173,16 +392,21 @@ func (r *PostgresRepo) PRExists(ctx context.Context, id string) (bool, error) {
 CreatePullRequest создаёт новый PR и всех его ревьюверов.
 */
 func (r *PostgresRepo) CreatePullRequest(ctx context.Context, pr model.PullRequest) error {
-	tx, err := r.db.BeginTx(ctx, nil)
+	tx, err := r.beginTx(ctx)
 	if err != nil {
 		return err
 	}
 	defer func() { _ = tx.Rollback() }()
 
+	status := pr.Status
+	if status == "" {
+		status = model.PRStatusOpen
+	}
+
 	_, err = tx.ExecContext(ctx, `
-		INSERT INTO pull_requests(pull_request_id, pull_request_name, author_id, status, created_at)
-		VALUES ($1, $2, $3, 'OPEN', $4)
-	`, pr.ID, pr.Name, pr.AuthorID, pr.CreatedAt)
+		INSERT INTO pull_requests(pull_request_id, pull_request_name, author_id, status, created_at, labels)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, pr.ID, pr.Name, pr.AuthorID, status, pr.CreatedAt, strings.Join(pr.Labels, " "))
 	if err != nil {
 		return err
 	}
@@ -197,6 +421,16 @@ func (r *PostgresRepo) CreatePullRequest(ctx context.Context, pr model.PullReque
 		}
 	}
 
+	for i, path := range pr.ChangedPaths {
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO pull_request_paths(pull_request_id, position, path)
+			VALUES ($1, $2, $3)
+		`, pr.ID, i, path)
+		if err != nil {
+			return err
+		}
+	}
+
 	return tx.Commit()
 }
 
@@ -205,52 +439,86 @@ GetPullRequestWithReviewers возвращает полный объект PR
 вместе со списком его ревьюверов.
 */
 func (r *PostgresRepo) GetPullRequestWithReviewers(ctx context.Context, id string) (*model.PullRequest, error) {
-	row := r.db.QueryRowContext(ctx, `
-		SELECT pull_request_id, pull_request_name, author_id, status, created_at, merged_at
+	row := r.conn(ctx).QueryRowContext(ctx, `
+		SELECT pull_request_id, pull_request_name, author_id, status, created_at, merged_at, closed_at, merge_override, labels
 		FROM pull_requests
 		WHERE pull_request_id=$1
 	`, id)
 
 	var pr model.PullRequest
-	if err := row.Scan(&pr.ID, &pr.Name, &pr.AuthorID, &pr.Status, &pr.CreatedAt, &pr.MergedAt); err != nil {
+	var labels string
+	err := row.Scan(&pr.ID, &pr.Name, &pr.AuthorID, &pr.Status,
+		&pr.CreatedAt, &pr.MergedAt, &pr.ClosedAt, &pr.MergeOverride, &labels)
+	if err != nil {
 		return nil, err
 	}
+	pr.Labels = strings.Fields(labels)
+	pr.AssignedReviewers = []string{}
+	pr.Reviews = []model.ReviewerState{}
 
-	revRows, err := r.db.QueryContext(ctx, `
-		SELECT user_id FROM pull_request_reviewers
+	revRows, err := r.conn(ctx).QueryContext(ctx, `
+		SELECT user_id, state, assigned_at, submitted_at, fallback_team, owner_pattern, match_reason, matched_tags
+		FROM pull_request_reviewers
 		WHERE pull_request_id=$1
+		ORDER BY assigned_at, user_id
 	`, pr.ID)
 	if err != nil {
 		return nil, err
 	}
 	defer func() { _ = revRows.Close() }()
 
-	var revs []string
 	for revRows.Next() {
-		var uid string
-		if err := revRows.Scan(&uid); err != nil {
+		var rs model.ReviewerState
+		var matched string
+		if err := revRows.Scan(&rs.UserID, &rs.State, &rs.AssignedAt, &rs.SubmittedAt,
+			&rs.FallbackTeam, &rs.OwnerPattern, &rs.MatchReason, &matched); err != nil {
 			return nil, err
 		}
-		revs = append(revs, uid)
+		if matched != "" {
+			rs.MatchedTags = strings.Fields(matched)
+		}
+		pr.AssignedReviewers = append(pr.AssignedReviewers, rs.UserID)
+		pr.Reviews = append(pr.Reviews, rs)
 	}
 
 	if err := revRows.Err(); err != nil {
 		return nil, err
 	}
 
-	pr.AssignedReviewers = revs
+	pathRows, err := r.conn(ctx).QueryContext(ctx, `
+		SELECT path FROM pull_request_paths WHERE pull_request_id=$1 ORDER BY position
+	`, pr.ID)
+	if err != nil {
+		return nil, err
+	}
+	if pr.ChangedPaths, err = scanIDs(pathRows); err != nil {
+		return nil, err
+	}
+
 	return &pr, nil
 }
 
+/*
+LockPullRequest блокирует строку PR (SELECT ... FOR UPDATE) до конца
+транзакции InTx. Возвращает sql.ErrNoRows, если PR нет.
+*/
+func (r *PostgresRepo) LockPullRequest(ctx context.Context, id string) error {
+	var locked string
+	return r.conn(ctx).QueryRowContext(ctx, `
+		SELECT pull_request_id FROM pull_requests WHERE pull_request_id=$1 FOR UPDATE
+	`, id).Scan(&locked)
+}
+
 /*
 SetPRMerged изменяет статус PR на MERGED и устанавливает merged_at.
+override отмечает мердж в обход политики одобрений.
 */
-func (r *PostgresRepo) SetPRMerged(ctx context.Context, id string, mergedAt sql.NullTime) (*model.PullRequest, error) {
-	_, err := r.db.ExecContext(ctx, `
+func (r *PostgresRepo) SetPRMerged(ctx context.Context, id string, mergedAt sql.NullTime, override bool) (*model.PullRequest, error) {
+	_, err := r.conn(ctx).ExecContext(ctx, `
 		UPDATE pull_requests
-		SET status='MERGED', merged_at=$2
+		SET status='MERGED', merged_at=$2, merge_override=$3
 		WHERE pull_request_id=$1
-	`, id, mergedAt)
+	`, id, mergedAt, override)
 	if err != nil {
 		return nil, err
 	}
@@ -258,19 +526,46 @@ func (r *PostgresRepo) SetPRMerged(ctx context.Context, id string, mergedAt sql.
 	return r.GetPullRequestWithReviewers(ctx, id)
 }
 
+/*
+SetPRStatus меняет статус PR. closed_at выставляется в at для CLOSED
+и сбрасывается для остальных статусов. MERGED ставится через SetPRMerged.
+Возвращает sql.ErrNoRows, если PR нет.
+*/
+func (r *PostgresRepo) SetPRStatus(ctx context.Context, id string, status model.PullRequestStatus, at time.Time) error {
+	closedAt := sql.NullTime{Time: at, Valid: status == model.PRStatusClosed}
+	res, err := r.conn(ctx).ExecContext(ctx, `
+		UPDATE pull_requests
+		SET status=$2, closed_at=$3
+		WHERE pull_request_id=$1
+	`, id, status, closedAt)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
 /*
 SetPRReviewers заменяет список ревьюверов PR на новый.
+Решения оставшихся ревьюверов сохраняются, новые получают состояние PENDING.
 */
 func (r *PostgresRepo) SetPRReviewers(ctx context.Context, id string, reviewers []string) error {
-	tx, err := r.db.BeginTx(ctx, nil)
+	tx, err := r.beginTx(ctx)
 	if err != nil {
 		return err
 	}
 	defer func() { _ = tx.Rollback() }()
 
 	_, err = tx.ExecContext(ctx,
-		`DELETE FROM pull_request_reviewers WHERE pull_request_id=$1`,
-		id,
+		`DELETE FROM pull_request_reviewers WHERE pull_request_id=$1 AND user_id <> ALL($2)`,
+		id, userIDArray(reviewers),
 	)
 	if err != nil {
 		return err
@@ -279,7 +574,8 @@ func (r *PostgresRepo) SetPRReviewers(ctx context.Context, id string, reviewers
 	for _, rid := range reviewers {
 		_, err := tx.ExecContext(ctx,
 			`INSERT INTO pull_request_reviewers(pull_request_id, user_id)
-			 VALUES ($1, $2)`, id, rid)
+			 VALUES ($1, $2)
+			 ON CONFLICT (pull_request_id, user_id) DO NOTHING`, id, rid)
 		if err != nil {
 			return err
 		}
@@ -289,42 +585,83 @@ func (r *PostgresRepo) SetPRReviewers(ctx context.Context, id string, reviewers
 }
 
 /*
-GetRandomActiveReviewersFromTeamExcluding выбирает случайных активных участников
-команды, исключая указанных пользователей.
+SetReviewState сохраняет решение ревьювера по PR.
+Возвращает sql.ErrNoRows, если пользователь не назначен на этот PR.
 */
-func (r *PostgresRepo) GetRandomActiveReviewersFromTeamExcluding(
-	ctx context.Context, team string, limit int, exclude []string) ([]string, error) {
-
-	ex := "("
-	for i, e := range exclude {
-		if i == 0 {
-			ex += fmt.Sprintf("'%s'", e)
-		} else {
-			ex += fmt.Sprintf(", '%s'", e)
-		}
+func (r *PostgresRepo) SetReviewState(ctx context.Context, prID, userID string, state model.ReviewState, at time.Time) error {
+	res, err := r.conn(ctx).ExecContext(ctx, `
+		UPDATE pull_request_reviewers
+		SET state=$3, submitted_at=$4
+		WHERE pull_request_id=$1 AND user_id=$2
+	`, prID, userID, state, at)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
 	}
-	ex += ")"
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
 
-	query := `
-		SELECT user_id FROM users
-		WHERE team_name=$1 AND is_active=true AND user_id NOT IN ` + ex + `
-		ORDER BY random()
-		LIMIT $2
-	`
+/*
+SetReviewerOrigin сохраняет, откуда взят ревьювер PR.
+Возвращает sql.ErrNoRows, если пользователь не назначен на этот PR.
+*/
+func (r *PostgresRepo) SetReviewerOrigin(ctx context.Context, prID, userID string, o model.ReviewerOrigin) error {
+	res, err := r.conn(ctx).ExecContext(ctx, `
+		UPDATE pull_request_reviewers
+		SET fallback_team=$3, owner_pattern=$4, match_reason=$5, matched_tags=$6
+		WHERE pull_request_id=$1 AND user_id=$2
+	`, prID, userID, o.FallbackTeam, o.OwnerPattern, o.MatchReason, strings.Join(o.MatchedTags, " "))
+	if err != nil {
+		return err
+	}
 
-	rows, err := r.db.QueryContext(ctx, query, team, limit)
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+/*
+GetReviewCandidates возвращает всех активных и доступных сейчас участников команды,
+кроме указанных пользователей, вместе с их текущей нагрузкой и лимитом, в порядке user_id.
+Лимит здесь не применяется — это задача вызывающей стороны.
+*/
+func (r *PostgresRepo) GetReviewCandidates(
+	ctx context.Context, team string, exclude []string) ([]model.ReviewCandidate, error) {
+
+	rows, err := r.conn(ctx).QueryContext(ctx, `
+		SELECT u.user_id, COALESCE(l.open_reviews, 0), u.max_open_reviews, u.tags, u.level
+		FROM users u
+		LEFT JOIN (`+openReviewsQuery+`) l ON l.user_id = u.user_id
+		WHERE u.team_name=$1 AND u.is_active=true AND u.user_id <> ALL($2)
+			AND `+availableCond+`
+		ORDER BY u.user_id
+	`, team, userIDArray(exclude))
 	if err != nil {
 		return nil, err
 	}
 	defer func() { _ = rows.Close() }()
 
-	result := []string{}
+	result := []model.ReviewCandidate{}
 	for rows.Next() {
-		var uid string
-		if err := rows.Scan(&uid); err != nil {
+		var c model.ReviewCandidate
+		var tags string
+		if err := rows.Scan(&c.UserID, &c.OpenReviews, &c.MaxOpenReviews, &tags, &c.Level); err != nil {
 			return nil, err
 		}
-		result = append(result, uid)
+		c.Tags = strings.Fields(tags)
+		result = append(result, c)
 	}
 
 	if err := rows.Err(); err != nil {
@@ -339,7 +676,7 @@ GetPullRequestsByReviewer возвращает список PR,
 где пользователь является ревьювером.
 */
 func (r *PostgresRepo) GetPullRequestsByReviewer(ctx context.Context, uid string) ([]model.PullRequestShort, error) {
-	rows, err := r.db.QueryContext(ctx, `
+	rows, err := r.conn(ctx).QueryContext(ctx, `
 		SELECT pr.pull_request_id, pr.pull_request_name, pr.author_id, pr.status
 		FROM pull_requests pr
 		JOIN pull_request_reviewers r ON r.pull_request_id = pr.pull_request_id
@@ -366,13 +703,306 @@ func (r *PostgresRepo) GetPullRequestsByReviewer(ctx context.Context, uid string
 	return result, nil
 }
 
+/*
+ListPullRequests возвращает до f.Limit PR, подходящих под фильтр f,
+в порядке f.Sort, начиная после курсора f.After.
+*/
+func (r *PostgresRepo) ListPullRequests(ctx context.Context, f model.PullRequestFilter) ([]model.PullRequestShort, error) {
+	q, args := pullRequestListQuery(f,
+		func(n int) string { return "$" + strconv.Itoa(n) },
+		func(t time.Time) interface{} { return t },
+	)
+	rows, err := r.conn(ctx).QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	result := []model.PullRequestShort{}
+	for rows.Next() {
+		var p model.PullRequestShort
+		if err := rows.Scan(&p.ID, &p.Name, &p.AuthorID, &p.Status, &p.CreatedAt, &p.MergedAt); err != nil {
+			return nil, err
+		}
+		result = append(result, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+/*
+GetUnderstaffedPullRequests возвращает id открытых PR авторов из команды team,
+у которых меньше want ревьюверов, от старых к новым.
+*/
+func (r *PostgresRepo) GetUnderstaffedPullRequests(ctx context.Context, team string, want int) ([]string, error) {
+	rows, err := r.conn(ctx).QueryContext(ctx, `
+		SELECT pr.pull_request_id
+		FROM pull_requests pr
+		JOIN users a ON a.user_id = pr.author_id
+		LEFT JOIN pull_request_reviewers r ON r.pull_request_id = pr.pull_request_id
+		WHERE a.team_name=$1 AND pr.status = 'OPEN'
+		GROUP BY pr.pull_request_id, pr.created_at
+		HAVING COUNT(r.user_id) < $2
+		ORDER BY pr.created_at, pr.pull_request_id
+	`, team, want)
+	if err != nil {
+		return nil, err
+	}
+	return scanIDs(rows)
+}
+
+/*
+GetRecentReviewers возвращает ревьюверов последних n PR автора, у которых
+есть ревьюверы: по списку на PR, от новых к старым.
+*/
+func (r *PostgresRepo) GetRecentReviewers(ctx context.Context, author string, n int) ([][]string, error) {
+	rows, err := r.conn(ctx).QueryContext(ctx, `
+		SELECT r.pull_request_id, r.user_id
+		FROM pull_request_reviewers r
+		JOIN (
+			SELECT pr.pull_request_id, pr.created_at
+			FROM pull_requests pr
+			WHERE pr.author_id=$1 AND EXISTS (
+				SELECT 1 FROM pull_request_reviewers x WHERE x.pull_request_id = pr.pull_request_id)
+			ORDER BY pr.created_at DESC, pr.pull_request_id DESC
+			LIMIT $2
+		) p ON p.pull_request_id = r.pull_request_id
+		ORDER BY p.created_at DESC, p.pull_request_id DESC, r.user_id
+	`, author, n)
+	if err != nil {
+		return nil, err
+	}
+	return scanRecentReviewers(rows)
+}
+
+/*
+CreateAssignment сохраняет запись о выборе ревьюверов PR.
+Возвращает запись с присвоенным assignment_id.
+*/
+func (r *PostgresRepo) CreateAssignment(ctx context.Context, a model.Assignment) (*model.Assignment, error) {
+	excluded, steps, chosen, err := assignmentJSON(a)
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.conn(ctx).QueryRowContext(ctx, `
+		INSERT INTO pull_request_assignments(pull_request_id, kind, seed, strategy, excluded, steps, chosen, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING assignment_id
+	`, a.PullRequestID, a.Kind, a.Seed, a.Strategy, excluded, steps, chosen, a.CreatedAt).Scan(&a.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// ListAssignments возвращает записи о выборе ревьюверов PR в порядке создания.
+func (r *PostgresRepo) ListAssignments(ctx context.Context, prID string) ([]model.Assignment, error) {
+	rows, err := r.conn(ctx).QueryContext(ctx, `
+		SELECT assignment_id, pull_request_id, kind, seed, strategy, excluded, steps, chosen, created_at
+		FROM pull_request_assignments
+		WHERE pull_request_id=$1
+		ORDER BY assignment_id
+	`, prID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	result := []model.Assignment{}
+	for rows.Next() {
+		var a model.Assignment
+		var excluded, steps, chosen []byte
+		if err := rows.Scan(&a.ID, &a.PullRequestID, &a.Kind, &a.Seed, &a.Strategy,
+			&excluded, &steps, &chosen, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		if err := decodeAssignment(&a, excluded, steps, chosen); err != nil {
+			return nil, err
+		}
+		result = append(result, a)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+/*
+pullRequestListQuery строит запрос ListPullRequests для фильтра f.
+ph возвращает плейсхолдер n-го аргумента, ts приводит время к виду,
+в котором его хранит СУБД. Курсор сравнивается с парой (поле сортировки,
+pull_request_id), так что PR с одинаковым временем не теряются между страницами.
+*/
+func pullRequestListQuery(f model.PullRequestFilter, ph func(n int) string, ts func(t time.Time) interface{}) (string, []interface{}) {
+	var where []string
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return ph(len(args))
+	}
+
+	key := "pr.created_at"
+	if f.Sort.ByMerged() {
+		key = "pr.merged_at"
+		where = append(where, "pr.merged_at IS NOT NULL")
+	}
+
+	if len(f.Statuses) > 0 {
+		ps := make([]string, len(f.Statuses))
+		for i, st := range f.Statuses {
+			ps[i] = arg(string(st))
+		}
+		where = append(where, "pr.status IN ("+strings.Join(ps, ", ")+")")
+	}
+	if f.AuthorID != "" {
+		where = append(where, "pr.author_id = "+arg(f.AuthorID))
+	}
+	if f.ReviewerID != "" {
+		where = append(where, `EXISTS (
+			SELECT 1 FROM pull_request_reviewers r
+			WHERE r.pull_request_id = pr.pull_request_id AND r.user_id = `+arg(f.ReviewerID)+`)`)
+	}
+	if f.Team != "" {
+		where = append(where, "pr.author_id IN (SELECT user_id FROM users WHERE team_name = "+arg(f.Team)+")")
+	}
+
+	bounds := []struct {
+		cond string
+		at   *time.Time
+	}{
+		{"pr.created_at >= ", f.CreatedFrom},
+		{"pr.created_at < ", f.CreatedTo},
+		{"pr.merged_at >= ", f.MergedFrom},
+		{"pr.merged_at < ", f.MergedTo},
+	}
+	for _, b := range bounds {
+		if b.at != nil {
+			where = append(where, b.cond+arg(ts(*b.at)))
+		}
+	}
+
+	dir, cmp := "ASC", ">"
+	if f.Sort.Desc() {
+		dir, cmp = "DESC", "<"
+	}
+	if f.After != nil {
+		where = append(where, fmt.Sprintf("(%s %s %s OR (%s = %s AND pr.pull_request_id %s %s))",
+			key, cmp, arg(ts(f.After.At)), key, arg(ts(f.After.At)), cmp, arg(f.After.ID)))
+	}
+
+	q := `
+		SELECT pr.pull_request_id, pr.pull_request_name, pr.author_id, pr.status, pr.created_at, pr.merged_at
+		FROM pull_requests pr`
+	if len(where) > 0 {
+		q += " WHERE " + strings.Join(where, " AND ")
+	}
+	q += " ORDER BY " + key + " " + dir + ", pr.pull_request_id " + dir
+	q += " LIMIT " + arg(f.Limit)
+	return q, args
+}
+
+// assignmentJSON кодирует списки записи о выборе ревьюверов для JSON-колонок.
+func assignmentJSON(a model.Assignment) (excluded, steps, chosen string, err error) {
+	if a.Steps == nil {
+		a.Steps = []model.AssignmentStep{}
+	}
+	b, err := json.Marshal(a.Steps)
+	if err != nil {
+		return "", "", "", err
+	}
+	return jsonIDs(a.Excluded), string(b), jsonIDs(a.Chosen), nil
+}
+
+// decodeAssignment разбирает JSON-колонки записи о выборе ревьюверов.
+func decodeAssignment(a *model.Assignment, excluded, steps, chosen []byte) error {
+	if err := json.Unmarshal(excluded, &a.Excluded); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(steps, &a.Steps); err != nil {
+		return err
+	}
+	return json.Unmarshal(chosen, &a.Chosen)
+}
+
+// scanRecentReviewers группирует строки (pull_request_id, user_id) по PR и закрывает rows.
+func scanRecentReviewers(rows *sql.Rows) ([][]string, error) {
+	defer func() { _ = rows.Close() }()
+
+	result := [][]string{}
+	last := ""
+	for rows.Next() {
+		var prID, uid string
+		if err := rows.Scan(&prID, &uid); err != nil {
+			return nil, err
+		}
+		if len(result) == 0 || prID != last {
+			result = append(result, []string{})
+			last = prID
+		}
+		result[len(result)-1] = append(result[len(result)-1], uid)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// scanOwnershipRules читает строки (pattern, owners) и закрывает rows.
+func scanOwnershipRules(rows *sql.Rows) ([]model.OwnershipRule, error) {
+	defer func() { _ = rows.Close() }()
+
+	result := []model.OwnershipRule{}
+	for rows.Next() {
+		var rule model.OwnershipRule
+		var owners string
+		if err := rows.Scan(&rule.Pattern, &owners); err != nil {
+			return nil, err
+		}
+		rule.Owners = strings.Fields(owners)
+		result = append(result, rule)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// scanIDs читает строки из одной текстовой колонки и закрывает rows.
+func scanIDs(rows *sql.Rows) ([]string, error) {
+	defer func() { _ = rows.Close() }()
+
+	result := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		result = append(result, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
 // GetReviewerAssignmentStats возвращает количество назначений ревьюверов по каждому пользователю.
+// open_assignments учитывает только PR в статусе OPEN.
 func (r *PostgresRepo) GetReviewerAssignmentStats(ctx context.Context) ([]model.ReviewerStat, error) {
-	rows, err := r.db.QueryContext(ctx, `
-		SELECT user_id, COUNT(*) AS assignments
-		FROM pull_request_reviewers
-		GROUP BY user_id
-		ORDER BY assignments DESC
+	rows, err := r.conn(ctx).QueryContext(ctx, `
+		SELECT r.user_id,
+			COUNT(*) AS assignments,
+			COUNT(*) FILTER (WHERE pr.status = 'OPEN') AS open_assignments
+		FROM pull_request_reviewers r
+		JOIN pull_requests pr ON pr.pull_request_id = r.pull_request_id
+		GROUP BY r.user_id
+		ORDER BY assignments DESC, r.user_id
 	`)
 	if err != nil {
 		return nil, err
@@ -382,7 +1012,7 @@ func (r *PostgresRepo) GetReviewerAssignmentStats(ctx context.Context) ([]model.
 	var stats []model.ReviewerStat
 	for rows.Next() {
 		var s model.ReviewerStat
-		if err := rows.Scan(&s.UserID, &s.Assignments); err != nil {
+		if err := rows.Scan(&s.UserID, &s.Assignments, &s.OpenAssignments); err != nil {
 			return nil, err
 		}
 		stats = append(stats, s)
@@ -394,3 +1024,76 @@ func (r *PostgresRepo) GetReviewerAssignmentStats(ctx context.Context) ([]model.
 
 	return stats, nil
 }
+
+// openReviewsQuery считает ревью каждого пользователя в открытых PR.
+const openReviewsQuery = `
+	SELECT r.user_id, COUNT(*) AS open_reviews
+	FROM pull_request_reviewers r
+	JOIN pull_requests pr ON pr.pull_request_id = r.pull_request_id
+	WHERE pr.status = 'OPEN'
+	GROUP BY r.user_id`
+
+// availableCond отсекает пользователей u, у которых сейчас идёт окно недоступности.
+const availableCond = `NOT EXISTS (
+	SELECT 1 FROM user_unavailability w
+	WHERE w.user_id = u.user_id AND w.starts_at <= now() AND now() < w.ends_at)`
+
+// teamSettingsColumns — колонки teams в порядке, который читает scanTeamSettings.
+const teamSettingsColumns = `name, auto_reassign_on_deactivate, min_approvals, block_on_changes_requested,
+	reviewer_count, min_reviewers, max_reviewers, rotation_window, require_senior`
+
+// scanTeamSettings читает строку, выбранную по teamSettingsColumns.
+func scanTeamSettings(row *sql.Row) (*model.TeamSettings, error) {
+	var ts model.TeamSettings
+	err := row.Scan(&ts.TeamName, &ts.AutoReassignOnDeactivate, &ts.MinApprovals, &ts.BlockOnChangesRequested,
+		&ts.ReviewerCount, &ts.MinReviewers, &ts.MaxReviewers, &ts.RotationWindow, &ts.RequireSenior)
+	if err != nil {
+		return nil, err
+	}
+	return &ts, nil
+}
+
+// teamSettingsArgs — значения изменяемых колонок teams в порядке teamSettingsColumns без name.
+func teamSettingsArgs(ts model.TeamSettings) []interface{} {
+	return []interface{}{ts.AutoReassignOnDeactivate, ts.MinApprovals, ts.BlockOnChangesRequested,
+		ts.ReviewerCount, ts.MinReviewers, ts.MaxReviewers, ts.RotationWindow, ts.RequireSenior}
+}
+
+// userColumns — колонки users в порядке, который читает scanUser.
+const userColumns = `user_id, username, team_name, is_active, max_open_reviews, tags, level`
+
+// scanUser читает строку, выбранную по userColumns.
+func scanUser(row *sql.Row) (*model.User, error) {
+	var u model.User
+	var tags string
+	if err := row.Scan(&u.UserID, &u.Username, &u.TeamName, &u.IsActive, &u.MaxOpenReviews, &tags, &u.Level); err != nil {
+		return nil, err
+	}
+	u.Tags = strings.Fields(tags)
+	return &u, nil
+}
+
+// tagsArg передаёт теги для COALESCE: nil оставляет колонку без изменений.
+func tagsArg(tags *[]string) interface{} {
+	if tags == nil {
+		return nil
+	}
+	return strings.Join(*tags, " ")
+}
+
+// levelArg подставляет уровень по умолчанию, если он не указан.
+func levelArg(l model.Level) model.Level {
+	if l == "" {
+		return model.DefaultLevel
+	}
+	return l
+}
+
+// userIDArray передаёт список user_id как text[]; nil превращается в пустой массив,
+// иначе `<> ALL(NULL)` отфильтрует все строки.
+func userIDArray(ids []string) interface{} {
+	if ids == nil {
+		ids = []string{}
+	}
+	return pq.Array(ids)
+}
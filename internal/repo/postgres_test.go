@@ -0,0 +1,53 @@
+package repo
+
+import (
+	"database/sql/driver"
+	"reflect"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+func TestUserIDArrayHostileIDs(t *testing.T) {
+	tests := []struct {
+		name string
+		ids  []string
+		want []string
+	}{
+		{"nil", nil, []string{}},
+		{"empty", []string{}, []string{}},
+		{"quotes and backslashes", []string{
+			"o'brien",
+			"x') OR ('1'='1",
+			`back\slash"quote`,
+			`\`,
+			`"`,
+			"{braces,comma}",
+			"NULL",
+		}, nil},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			v, err := userIDArray(tc.ids).(driver.Valuer).Value()
+			if err != nil {
+				t.Fatalf("Value: %v", err)
+			}
+			if v == nil {
+				t.Fatalf("userIDArray(%q) encodes to NULL, want an array", tc.ids)
+			}
+
+			var got pq.StringArray
+			if err := got.Scan(v); err != nil {
+				t.Fatalf("Scan(%v): %v", v, err)
+			}
+			want := tc.want
+			if want == nil {
+				want = tc.ids
+			}
+			if !reflect.DeepEqual([]string(got), want) {
+				t.Fatalf("round trip of %q: got %q", tc.ids, []string(got))
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package repotest
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lib/pq"
+	_ "modernc.org/sqlite"
+
+	"pr-review-service/internal/db"
+	"pr-review-service/internal/repo"
+	"pr-review-service/internal/repo/memory"
+	"pr-review-service/internal/service"
+)
+
+// Memory — Factory для репозитория в памяти.
+func Memory(t *testing.T) service.Repo {
+	return memory.NewRepo()
+}
+
+/*
+Postgres — Factory для PostgresRepo. Подключается к TEST_DATABASE_DSN,
+применяет миграции и очищает все таблицы перед тестом.
+Без TEST_DATABASE_DSN тест пропускается.
+*/
+func Postgres(t *testing.T) service.Repo {
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN is not set")
+	}
+
+	conn, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("open postgres: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	if err := db.Migrate(conn, db.Postgres); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	truncateAll(t, conn)
+
+	return repo.NewPostgresRepo(conn)
+}
+
+/*
+SQLite — Factory для SQLiteRepo на свежем файле базы во временном
+каталоге теста; очистка не нужна.
+*/
+func SQLite(t *testing.T) service.Repo {
+	path := filepath.Join(t.TempDir(), "repotest.db")
+	conn, err := sql.Open("sqlite", "file:"+path+"?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	conn.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	if err := db.Migrate(conn, db.SQLite); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	return repo.NewSQLiteRepo(conn)
+}
+
+// truncateAll очищает все таблицы текущей схемы, кроме schema_migrations.
+func truncateAll(t *testing.T, conn *sql.DB) {
+	t.Helper()
+	ctx := context.Background()
+
+	rows, err := conn.QueryContext(ctx, `
+		SELECT tablename FROM pg_tables
+		WHERE schemaname = current_schema() AND tablename <> 'schema_migrations'
+	`)
+	if err != nil {
+		t.Fatalf("list tables: %v", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var tables []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatalf("list tables: %v", err)
+		}
+		tables = append(tables, pq.QuoteIdentifier(name))
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatalf("list tables: %v", err)
+	}
+	if len(tables) == 0 {
+		return
+	}
+
+	if _, err := conn.ExecContext(ctx,
+		`TRUNCATE `+strings.Join(tables, ", ")+` RESTART IDENTITY CASCADE`); err != nil {
+		t.Fatalf("truncate: %v", err)
+	}
+}
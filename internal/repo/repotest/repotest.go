@@ -0,0 +1,1251 @@
+/*
+Package repotest содержит общий набор проверок контракта service.Repo.
+
+Любая реализация репозитория должна проходить Run, чтобы сервис вёл себя
+одинаково на всех хранилищах. Эталоном поведения служит PostgresRepo.
+
+	func TestMemoryRepo(t *testing.T) {
+		repotest.Run(t, repotest.Memory)
+	}
+
+	func TestSQLiteRepo(t *testing.T) {
+		repotest.Run(t, repotest.SQLite)
+	}
+
+	func TestPostgresRepo(t *testing.T) {
+		repotest.Run(t, repotest.Postgres) // пропускается без TEST_DATABASE_DSN
+	}
+*/
+package repotest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"pr-review-service/internal/model"
+	"pr-review-service/internal/service"
+)
+
+// Factory создаёт пустой репозиторий для одного теста.
+type Factory func(t *testing.T) service.Repo
+
+// Run прогоняет все проверки контракта, создавая новый репозиторий для каждой.
+func Run(t *testing.T, newRepo Factory) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, r service.Repo)
+	}{
+		{"CreateTeamConflict", testCreateTeamConflict},
+		{"GetTeamNotFound", testGetTeamNotFound},
+		{"MemberUpsert", testMemberUpsert},
+		{"TeamSettings", testTeamSettings},
+		{"OwnershipRules", testOwnershipRules},
+		{"UserNotFound", testUserNotFound},
+		{"UpdateUser", testUpdateUser},
+		{"Unavailability", testUnavailability},
+		{"PullRequestLifecycle", testPullRequestLifecycle},
+		{"PullRequestNotFound", testPullRequestNotFound},
+		{"PullRequestWithoutReviewers", testPullRequestWithoutReviewers},
+		{"LockPullRequest", testLockPullRequest},
+		{"PullRequestStatus", testPullRequestStatus},
+		{"PathsAndLabels", testPathsAndLabels},
+		{"SetPRReviewers", testSetPRReviewers},
+		{"ReviewStates", testReviewStates},
+		{"ReviewerOrigin", testReviewerOrigin},
+		{"PullRequestsByReviewer", testPullRequestsByReviewer},
+		{"ListPullRequests", testListPullRequests},
+		{"UnderstaffedPullRequests", testUnderstaffedPullRequests},
+		{"RecentReviewers", testRecentReviewers},
+		{"Assignments", testAssignments},
+		{"StatsOrdering", testStatsOrdering},
+		{"CandidatesFiltering", testCandidatesFiltering},
+		{"CandidateCapacity", testCandidateCapacity},
+		{"OpenReviewCounts", testOpenReviewCounts},
+		{"HostileUserIDs", testHostileUserIDs},
+		{"InTx", testInTx},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.fn(t, newRepo(t))
+		})
+	}
+}
+
+func member(id string, active bool) model.TeamMember {
+	return model.TeamMember{UserID: id, Username: "user " + id, IsActive: active}
+}
+
+func mustCreateTeam(t *testing.T, r service.Repo, name string, members ...model.TeamMember) {
+	t.Helper()
+	if err := r.CreateTeamWithMembers(context.Background(), model.Team{TeamName: name, Members: members}); err != nil {
+		t.Fatalf("CreateTeamWithMembers(%s): %v", name, err)
+	}
+}
+
+func mustCreatePR(t *testing.T, r service.Repo, id, author string, reviewers ...string) {
+	t.Helper()
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	err := r.CreatePullRequest(context.Background(), model.PullRequest{
+		ID:                id,
+		Name:              "PR " + id,
+		AuthorID:          author,
+		Status:            model.PRStatusOpen,
+		AssignedReviewers: reviewers,
+		CreatedAt:         &now,
+	})
+	if err != nil {
+		t.Fatalf("CreatePullRequest(%s): %v", id, err)
+	}
+}
+
+func mustMerge(t *testing.T, r service.Repo, id string) {
+	t.Helper()
+	at := sql.NullTime{Time: time.Now().UTC(), Valid: true}
+	if _, err := r.SetPRMerged(context.Background(), id, at, false); err != nil {
+		t.Fatalf("SetPRMerged(%s): %v", id, err)
+	}
+}
+
+func sorted(ids []string) []string {
+	out := append([]string{}, ids...)
+	sort.Strings(out)
+	return out
+}
+
+func candidateIDs(cs []model.ReviewCandidate) []string {
+	out := []string{}
+	for _, c := range cs {
+		out = append(out, c.UserID)
+	}
+	return out
+}
+
+func testCreateTeamConflict(t *testing.T, r service.Repo) {
+	ctx := context.Background()
+	mustCreateTeam(t, r, "backend", member("u1", true))
+
+	err := r.CreateTeamWithMembers(ctx, model.Team{TeamName: "backend", Members: []model.TeamMember{member("u2", true)}})
+	if err == nil || err.Error() != "team_exists" {
+		t.Fatalf("second CreateTeamWithMembers: got %v, want team_exists", err)
+	}
+
+	// Неудачное создание не должно затронуть участников.
+	if _, err := r.GetUserByID(ctx, "u2"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("GetUserByID(u2) after conflict: got %v, want sql.ErrNoRows", err)
+	}
+}
+
+func testGetTeamNotFound(t *testing.T, r service.Repo) {
+	if _, err := r.GetTeam(context.Background(), "missing"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("GetTeam(missing): got %v, want sql.ErrNoRows", err)
+	}
+
+	mustCreateTeam(t, r, "empty")
+	team, err := r.GetTeam(context.Background(), "empty")
+	if err != nil {
+		t.Fatalf("GetTeam(empty): %v", err)
+	}
+	if len(team.Members) != 0 {
+		t.Fatalf("GetTeam(empty): got %d members, want 0", len(team.Members))
+	}
+}
+
+func testMemberUpsert(t *testing.T, r service.Repo) {
+	ctx := context.Background()
+	mustCreateTeam(t, r, "a", member("u1", true), member("u2", true))
+
+	moved := model.TeamMember{UserID: "u1", Username: "Renamed", IsActive: false, MaxOpenReviews: 3,
+		Tags: []string{"go", "postgres"}, Level: model.LevelSenior}
+	mustCreateTeam(t, r, "b", moved)
+
+	u, err := r.GetUserByID(ctx, "u1")
+	if err != nil {
+		t.Fatalf("GetUserByID(u1): %v", err)
+	}
+	want := model.User{UserID: "u1", Username: "Renamed", TeamName: "b", IsActive: false, MaxOpenReviews: 3,
+		Tags: []string{"go", "postgres"}, Level: model.LevelSenior}
+	if !reflect.DeepEqual(*u, want) {
+		t.Fatalf("upserted user: got %+v, want %+v", *u, want)
+	}
+
+	a, err := r.GetTeam(ctx, "a")
+	if err != nil {
+		t.Fatalf("GetTeam(a): %v", err)
+	}
+	if len(a.Members) != 1 || a.Members[0].UserID != "u2" {
+		t.Fatalf("team a members: got %+v, want only u2", a.Members)
+	}
+	if a.Members[0].Tags == nil || len(a.Members[0].Tags) != 0 {
+		t.Fatalf("member without tags: got %#v, want empty non-nil slice", a.Members[0].Tags)
+	}
+	if a.Members[0].Level != model.DefaultLevel {
+		t.Fatalf("member without level: got %q, want %q", a.Members[0].Level, model.DefaultLevel)
+	}
+
+	b, err := r.GetTeam(ctx, "b")
+	if err != nil {
+		t.Fatalf("GetTeam(b): %v", err)
+	}
+	if len(b.Members) != 1 || !reflect.DeepEqual(b.Members[0].Tags, moved.Tags) {
+		t.Fatalf("team b members: got %+v, want u1 with tags %v", b.Members, moved.Tags)
+	}
+}
+
+func testTeamSettings(t *testing.T, r service.Repo) {
+	ctx := context.Background()
+	if _, err := r.GetTeamSettings(ctx, "missing"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("GetTeamSettings(missing): got %v, want sql.ErrNoRows", err)
+	}
+	if err := r.UpdateTeamSettings(ctx, model.TeamSettings{TeamName: "missing"}); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("UpdateTeamSettings(missing): got %v, want sql.ErrNoRows", err)
+	}
+
+	mustCreateTeam(t, r, "backend")
+	ts, err := r.GetTeamSettings(ctx, "backend")
+	if err != nil {
+		t.Fatalf("GetTeamSettings: %v", err)
+	}
+	if ts.AutoReassignOnDeactivate {
+		t.Fatalf("new team: auto_reassign_on_deactivate must default to false")
+	}
+
+	if ts.MinApprovals != 0 || ts.BlockOnChangesRequested {
+		t.Fatalf("new team: merge policy must be disabled by default, got %+v", *ts)
+	}
+	if ts.ReviewerCount != model.DefaultReviewerCount || ts.MinReviewers != 0 || ts.MaxReviewers != 0 {
+		t.Fatalf("new team: reviewer count defaults, got %+v", *ts)
+	}
+	if ts.RotationWindow != model.DefaultRotationWindow {
+		t.Fatalf("new team: rotation window default, got %+v", *ts)
+	}
+	if ts.FallbackTeams == nil || len(ts.FallbackTeams) != 0 {
+		t.Fatalf("new team: fallback teams must be empty non-nil slice, got %#v", ts.FallbackTeams)
+	}
+
+	mustCreateTeam(t, r, "platform")
+	mustCreateTeam(t, r, "frontend")
+	ts.AutoReassignOnDeactivate = true
+	ts.MinApprovals = 2
+	ts.BlockOnChangesRequested = true
+	ts.ReviewerCount = 3
+	ts.MinReviewers = 1
+	ts.MaxReviewers = 4
+	ts.RotationWindow = 10
+	ts.RequireSenior = true
+	ts.FallbackTeams = []string{"platform", "frontend"}
+	if err := r.UpdateTeamSettings(ctx, *ts); err != nil {
+		t.Fatalf("UpdateTeamSettings: %v", err)
+	}
+	got, err := r.GetTeamSettings(ctx, "backend")
+	if err != nil {
+		t.Fatalf("GetTeamSettings: %v", err)
+	}
+	if !reflect.DeepEqual(got, ts) {
+		t.Fatalf("settings round trip: got %+v, want %+v", got, ts)
+	}
+
+	ts.FallbackTeams = []string{"frontend"}
+	if err := r.UpdateTeamSettings(ctx, *ts); err != nil {
+		t.Fatalf("UpdateTeamSettings: %v", err)
+	}
+	got, err = r.GetTeamSettings(ctx, "backend")
+	if err != nil {
+		t.Fatalf("GetTeamSettings: %v", err)
+	}
+	if !reflect.DeepEqual(got.FallbackTeams, []string{"frontend"}) {
+		t.Fatalf("fallback teams must be replaced: got %v", got.FallbackTeams)
+	}
+}
+
+func testOwnershipRules(t *testing.T, r service.Repo) {
+	ctx := context.Background()
+	if err := r.SetOwnershipRules(ctx, "missing", nil); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("SetOwnershipRules(missing): got %v, want sql.ErrNoRows", err)
+	}
+
+	mustCreateTeam(t, r, "backend", member("u1", true))
+	rules, err := r.GetOwnershipRules(ctx, "backend")
+	if err != nil {
+		t.Fatalf("GetOwnershipRules: %v", err)
+	}
+	if rules == nil || len(rules) != 0 {
+		t.Fatalf("new team: got %#v, want empty non-nil slice", rules)
+	}
+
+	want := []model.OwnershipRule{
+		{Pattern: "*", Owners: []string{"@u1"}},
+		{Pattern: "/db/**", Owners: []string{"@acme/backend", "@u1"}},
+		{Pattern: "/vendor/", Owners: []string{}},
+	}
+	if err := r.SetOwnershipRules(ctx, "backend", want); err != nil {
+		t.Fatalf("SetOwnershipRules: %v", err)
+	}
+	rules, err = r.GetOwnershipRules(ctx, "backend")
+	if err != nil {
+		t.Fatalf("GetOwnershipRules: %v", err)
+	}
+	if !reflect.DeepEqual(rules, want) {
+		t.Fatalf("rules round trip: got %#v, want %#v", rules, want)
+	}
+
+	if err := r.SetOwnershipRules(ctx, "backend", want[:1]); err != nil {
+		t.Fatalf("SetOwnershipRules: %v", err)
+	}
+	rules, err = r.GetOwnershipRules(ctx, "backend")
+	if err != nil {
+		t.Fatalf("GetOwnershipRules: %v", err)
+	}
+	if !reflect.DeepEqual(rules, want[:1]) {
+		t.Fatalf("rules must be replaced: got %#v", rules)
+	}
+}
+
+func testUserNotFound(t *testing.T, r service.Repo) {
+	ctx := context.Background()
+	if _, err := r.GetUserByID(ctx, "missing"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("GetUserByID: got %v, want sql.ErrNoRows", err)
+	}
+	if _, err := r.UpdateUserIsActive(ctx, "missing", true); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("UpdateUserIsActive: got %v, want sql.ErrNoRows", err)
+	}
+	if _, err := r.UpdateUser(ctx, "missing", model.UserUpdate{}); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("UpdateUser: got %v, want sql.ErrNoRows", err)
+	}
+}
+
+func testUpdateUser(t *testing.T, r service.Repo) {
+	ctx := context.Background()
+	mustCreateTeam(t, r, "backend", member("u1", true))
+
+	limit := 2
+	u, err := r.UpdateUser(ctx, "u1", model.UserUpdate{MaxOpenReviews: &limit})
+	if err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	if u.MaxOpenReviews != 2 || u.Username != "user u1" {
+		t.Fatalf("UpdateUser(max_open_reviews): got %+v", *u)
+	}
+
+	name := "Alice"
+	u, err = r.UpdateUser(ctx, "u1", model.UserUpdate{Username: &name})
+	if err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	if u.MaxOpenReviews != 2 || u.Username != "Alice" {
+		t.Fatalf("UpdateUser(username) must keep other fields: got %+v", *u)
+	}
+
+	tags := []string{"frontend", "postgres"}
+	u, err = r.UpdateUser(ctx, "u1", model.UserUpdate{Tags: &tags})
+	if err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	if !reflect.DeepEqual(u.Tags, tags) || u.Username != "Alice" {
+		t.Fatalf("UpdateUser(tags): got %+v", *u)
+	}
+	u, err = r.UpdateUser(ctx, "u1", model.UserUpdate{Username: &name})
+	if err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	if !reflect.DeepEqual(u.Tags, tags) {
+		t.Fatalf("UpdateUser without tags must keep them: got %v", u.Tags)
+	}
+	empty := []string{}
+	u, err = r.UpdateUser(ctx, "u1", model.UserUpdate{Tags: &empty})
+	if err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	if len(u.Tags) != 0 {
+		t.Fatalf("UpdateUser(tags=[]) must clear tags: got %v", u.Tags)
+	}
+
+	lead := model.LevelLead
+	u, err = r.UpdateUser(ctx, "u1", model.UserUpdate{Level: &lead})
+	if err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	if u.Level != model.LevelLead || u.Username != "Alice" {
+		t.Fatalf("UpdateUser(level): got %+v", *u)
+	}
+
+	u, err = r.UpdateUserIsActive(ctx, "u1", false)
+	if err != nil {
+		t.Fatalf("UpdateUserIsActive: %v", err)
+	}
+	if u.IsActive || u.Username != "Alice" {
+		t.Fatalf("UpdateUserIsActive: got %+v", *u)
+	}
+}
+
+func testUnavailability(t *testing.T, r service.Repo) {
+	ctx := context.Background()
+	mustCreateTeam(t, r, "backend", member("u1", true), member("u2", true))
+
+	now := time.Now().UTC().Truncate(time.Second)
+	past := model.UnavailabilityWindow{UserID: "u1", StartsAt: now.Add(-48 * time.Hour), EndsAt: now.Add(-24 * time.Hour)}
+	current := model.UnavailabilityWindow{UserID: "u1", StartsAt: now.Add(-time.Hour), EndsAt: now.Add(time.Hour), Reason: "vacation"}
+	future := model.UnavailabilityWindow{UserID: "u1", StartsAt: now.Add(24 * time.Hour), EndsAt: now.Add(48 * time.Hour)}
+
+	var created []*model.UnavailabilityWindow
+	for _, w := range []model.UnavailabilityWindow{future, past, current} {
+		got, err := r.CreateUnavailability(ctx, w)
+		if err != nil {
+			t.Fatalf("CreateUnavailability: %v", err)
+		}
+		if got.ID == 0 {
+			t.Fatalf("CreateUnavailability must assign an ID")
+		}
+		created = append(created, got)
+	}
+
+	list, err := r.ListUnavailability(ctx, "u1")
+	if err != nil {
+		t.Fatalf("ListUnavailability: %v", err)
+	}
+	if len(list) != 2 || list[0].Reason != "vacation" || list[1].ID != created[0].ID {
+		t.Fatalf("ListUnavailability must return current and future windows by start: got %+v", list)
+	}
+
+	cands, err := r.GetReviewCandidates(ctx, "backend", nil)
+	if err != nil {
+		t.Fatalf("GetReviewCandidates: %v", err)
+	}
+	if got := candidateIDs(cands); !reflect.DeepEqual(got, []string{"u2"}) {
+		t.Fatalf("user inside a window must not be a candidate: got %v", got)
+	}
+
+	if err := r.DeleteUnavailability(ctx, created[2].ID); err != nil {
+		t.Fatalf("DeleteUnavailability: %v", err)
+	}
+	if err := r.DeleteUnavailability(ctx, created[2].ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("second DeleteUnavailability: got %v, want sql.ErrNoRows", err)
+	}
+
+	cands, err = r.GetReviewCandidates(ctx, "backend", nil)
+	if err != nil {
+		t.Fatalf("GetReviewCandidates: %v", err)
+	}
+	if got := candidateIDs(cands); !reflect.DeepEqual(got, []string{"u1", "u2"}) {
+		t.Fatalf("after deleting the window: got %v, want [u1 u2]", got)
+	}
+}
+
+func testPullRequestLifecycle(t *testing.T, r service.Repo) {
+	ctx := context.Background()
+	mustCreateTeam(t, r, "backend", member("u1", true), member("u2", true), member("u3", true))
+
+	exists, err := r.PRExists(ctx, "pr-1")
+	if err != nil || exists {
+		t.Fatalf("PRExists before create: got %v, %v", exists, err)
+	}
+
+	mustCreatePR(t, r, "pr-1", "u1", "u2", "u3")
+
+	exists, err = r.PRExists(ctx, "pr-1")
+	if err != nil || !exists {
+		t.Fatalf("PRExists after create: got %v, %v", exists, err)
+	}
+
+	pr, err := r.GetPullRequestWithReviewers(ctx, "pr-1")
+	if err != nil {
+		t.Fatalf("GetPullRequestWithReviewers: %v", err)
+	}
+	if pr.Status != model.PRStatusOpen || pr.AuthorID != "u1" || pr.CreatedAt == nil || pr.MergedAt != nil {
+		t.Fatalf("created PR: got %+v", *pr)
+	}
+	if got := sorted(pr.AssignedReviewers); !reflect.DeepEqual(got, []string{"u2", "u3"}) {
+		t.Fatalf("created PR reviewers: got %v", got)
+	}
+
+	merged, err := r.SetPRMerged(ctx, "pr-1", sql.NullTime{Time: time.Now().UTC(), Valid: true}, true)
+	if err != nil {
+		t.Fatalf("SetPRMerged: %v", err)
+	}
+	if merged.Status != model.PRStatusMerged || merged.MergedAt == nil || !merged.MergeOverride {
+		t.Fatalf("merged PR: got %+v", *merged)
+	}
+	if got := sorted(merged.AssignedReviewers); !reflect.DeepEqual(got, []string{"u2", "u3"}) {
+		t.Fatalf("merge must keep reviewers: got %v", got)
+	}
+
+	if err := r.CreatePullRequest(ctx, model.PullRequest{ID: "pr-1", Name: "dup", AuthorID: "u1"}); err == nil {
+		t.Fatalf("CreatePullRequest with duplicate ID must fail")
+	}
+	if err := r.CreatePullRequest(ctx, model.PullRequest{ID: "pr-2", Name: "x", AuthorID: "ghost"}); err == nil {
+		t.Fatalf("CreatePullRequest with unknown author must fail")
+	}
+}
+
+func testPullRequestNotFound(t *testing.T, r service.Repo) {
+	ctx := context.Background()
+	if _, err := r.GetPullRequestWithReviewers(ctx, "missing"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("GetPullRequestWithReviewers: got %v, want sql.ErrNoRows", err)
+	}
+	at := sql.NullTime{Time: time.Now(), Valid: true}
+	if _, err := r.SetPRMerged(ctx, "missing", at, false); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("SetPRMerged: got %v, want sql.ErrNoRows", err)
+	}
+}
+
+func testPullRequestWithoutReviewers(t *testing.T, r service.Repo) {
+	ctx := context.Background()
+	mustCreateTeam(t, r, "backend", member("u1", true), member("u2", true))
+	mustCreatePR(t, r, "pr-1", "u1")
+	mustCreatePR(t, r, "pr-2", "u1", "u2")
+
+	if err := r.SetPRReviewers(ctx, "pr-2", nil); err != nil {
+		t.Fatalf("SetPRReviewers(nil): %v", err)
+	}
+	for _, id := range []string{"pr-1", "pr-2"} {
+		pr, err := r.GetPullRequestWithReviewers(ctx, id)
+		if err != nil {
+			t.Fatalf("GetPullRequestWithReviewers(%s): %v", id, err)
+		}
+		if pr.AssignedReviewers == nil || len(pr.AssignedReviewers) != 0 ||
+			pr.Reviews == nil || len(pr.Reviews) != 0 {
+			t.Fatalf("%s without reviewers: got reviewers %#v, reviews %#v, want empty non-nil slices",
+				id, pr.AssignedReviewers, pr.Reviews)
+		}
+	}
+}
+
+func testLockPullRequest(t *testing.T, r service.Repo) {
+	ctx := context.Background()
+	mustCreateTeam(t, r, "backend", member("u1", true), member("u2", true))
+	mustCreatePR(t, r, "pr-1", "u1", "u2")
+
+	err := r.InTx(ctx, func(ctx context.Context) error {
+		if err := r.LockPullRequest(ctx, "pr-1"); err != nil {
+			return err
+		}
+		return r.SetPRReviewers(ctx, "pr-1", nil)
+	})
+	if err != nil {
+		t.Fatalf("LockPullRequest then SetPRReviewers in InTx: %v", err)
+	}
+	pr, err := r.GetPullRequestWithReviewers(ctx, "pr-1")
+	if err != nil || len(pr.AssignedReviewers) != 0 {
+		t.Fatalf("PR after locked update: got %+v, %v", pr, err)
+	}
+
+	err = r.InTx(ctx, func(ctx context.Context) error {
+		return r.LockPullRequest(ctx, "missing")
+	})
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("LockPullRequest(missing): got %v, want sql.ErrNoRows", err)
+	}
+}
+
+func testPullRequestStatus(t *testing.T, r service.Repo) {
+	ctx := context.Background()
+	mustCreateTeam(t, r, "backend", member("u1", true), member("u2", true))
+
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	err := r.CreatePullRequest(ctx, model.PullRequest{
+		ID: "pr-1", Name: "draft", AuthorID: "u1", Status: model.PRStatusDraft, CreatedAt: &now,
+	})
+	if err != nil {
+		t.Fatalf("CreatePullRequest(draft): %v", err)
+	}
+	pr, err := r.GetPullRequestWithReviewers(ctx, "pr-1")
+	if err != nil {
+		t.Fatalf("GetPullRequestWithReviewers: %v", err)
+	}
+	if pr.Status != model.PRStatusDraft || len(pr.AssignedReviewers) != 0 {
+		t.Fatalf("draft PR: got %+v", *pr)
+	}
+
+	if err := r.SetPRStatus(ctx, "pr-1", model.PRStatusClosed, now); err != nil {
+		t.Fatalf("SetPRStatus(CLOSED): %v", err)
+	}
+	pr, err = r.GetPullRequestWithReviewers(ctx, "pr-1")
+	if err != nil {
+		t.Fatalf("GetPullRequestWithReviewers: %v", err)
+	}
+	if pr.Status != model.PRStatusClosed || pr.ClosedAt == nil || !pr.ClosedAt.Equal(now) {
+		t.Fatalf("closed PR: got status %s, closedAt %v", pr.Status, pr.ClosedAt)
+	}
+
+	if err := r.SetPRStatus(ctx, "pr-1", model.PRStatusOpen, now); err != nil {
+		t.Fatalf("SetPRStatus(OPEN): %v", err)
+	}
+	pr, err = r.GetPullRequestWithReviewers(ctx, "pr-1")
+	if err != nil {
+		t.Fatalf("GetPullRequestWithReviewers: %v", err)
+	}
+	if pr.Status != model.PRStatusOpen || pr.ClosedAt != nil {
+		t.Fatalf("reopened PR: got status %s, closedAt %v", pr.Status, pr.ClosedAt)
+	}
+
+	if err := r.SetPRStatus(ctx, "missing", model.PRStatusOpen, now); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("SetPRStatus(missing): got %v, want sql.ErrNoRows", err)
+	}
+}
+
+func testPathsAndLabels(t *testing.T, r service.Repo) {
+	ctx := context.Background()
+	mustCreateTeam(t, r, "backend", member("u1", true), member("u2", true))
+
+	now := time.Now().UTC()
+	paths := []string{"db/schema.sql", "cmd/app/main.go"}
+	labels := []string{"postgres", "backend"}
+	err := r.CreatePullRequest(ctx, model.PullRequest{
+		ID:           "pr-1",
+		Name:         "PR pr-1",
+		AuthorID:     "u1",
+		CreatedAt:    &now,
+		ChangedPaths: paths,
+		Labels:       labels,
+	})
+	if err != nil {
+		t.Fatalf("CreatePullRequest: %v", err)
+	}
+	mustCreatePR(t, r, "pr-2", "u1", "u2")
+
+	pr, err := r.GetPullRequestWithReviewers(ctx, "pr-1")
+	if err != nil {
+		t.Fatalf("GetPullRequestWithReviewers: %v", err)
+	}
+	if !reflect.DeepEqual(pr.ChangedPaths, paths) {
+		t.Fatalf("changed paths must keep order: got %v, want %v", pr.ChangedPaths, paths)
+	}
+	if !reflect.DeepEqual(pr.Labels, labels) {
+		t.Fatalf("labels must keep order: got %v, want %v", pr.Labels, labels)
+	}
+
+	pr, err = r.GetPullRequestWithReviewers(ctx, "pr-2")
+	if err != nil {
+		t.Fatalf("GetPullRequestWithReviewers: %v", err)
+	}
+	if len(pr.ChangedPaths) != 0 || len(pr.Labels) != 0 {
+		t.Fatalf("PR without paths and labels: got %v, %v", pr.ChangedPaths, pr.Labels)
+	}
+}
+
+func testSetPRReviewers(t *testing.T, r service.Repo) {
+	ctx := context.Background()
+	mustCreateTeam(t, r, "backend", member("u1", true), member("u2", true), member("u3", true), member("u4", true))
+	mustCreatePR(t, r, "pr-1", "u1", "u3", "u2")
+
+	pr, err := r.GetPullRequestWithReviewers(ctx, "pr-1")
+	if err != nil {
+		t.Fatalf("GetPullRequestWithReviewers: %v", err)
+	}
+	if !reflect.DeepEqual(pr.AssignedReviewers, []string{"u2", "u3"}) {
+		t.Fatalf("reviewers assigned together must be ordered by user_id: got %v", pr.AssignedReviewers)
+	}
+
+	if err := r.SetPRReviewers(ctx, "pr-1", []string{"u4", "u3"}); err != nil {
+		t.Fatalf("SetPRReviewers: %v", err)
+	}
+	pr, err = r.GetPullRequestWithReviewers(ctx, "pr-1")
+	if err != nil {
+		t.Fatalf("GetPullRequestWithReviewers: %v", err)
+	}
+	if !reflect.DeepEqual(pr.AssignedReviewers, []string{"u3", "u4"}) {
+		t.Fatalf("reviewers must be replaced and ordered by assigned_at: got %v", pr.AssignedReviewers)
+	}
+
+	if err := r.SetPRReviewers(ctx, "pr-1", nil); err != nil {
+		t.Fatalf("SetPRReviewers(nil): %v", err)
+	}
+	pr, err = r.GetPullRequestWithReviewers(ctx, "pr-1")
+	if err != nil {
+		t.Fatalf("GetPullRequestWithReviewers: %v", err)
+	}
+	if len(pr.AssignedReviewers) != 0 {
+		t.Fatalf("reviewers must be cleared: got %v", pr.AssignedReviewers)
+	}
+
+	if err := r.SetPRReviewers(ctx, "pr-1", []string{"ghost"}); err == nil {
+		t.Fatalf("SetPRReviewers with unknown user must fail")
+	}
+}
+
+func reviewStates(pr *model.PullRequest) map[string]model.ReviewState {
+	result := map[string]model.ReviewState{}
+	for _, rs := range pr.Reviews {
+		result[rs.UserID] = rs.State
+	}
+	return result
+}
+
+func testReviewStates(t *testing.T, r service.Repo) {
+	ctx := context.Background()
+	mustCreateTeam(t, r, "backend", member("u1", true), member("u2", true), member("u3", true), member("u4", true))
+	mustCreatePR(t, r, "pr-1", "u1", "u2", "u3")
+
+	pr, err := r.GetPullRequestWithReviewers(ctx, "pr-1")
+	if err != nil {
+		t.Fatalf("GetPullRequestWithReviewers: %v", err)
+	}
+	want := map[string]model.ReviewState{"u2": model.ReviewPending, "u3": model.ReviewPending}
+	if got := reviewStates(pr); !reflect.DeepEqual(got, want) {
+		t.Fatalf("new reviewers must be PENDING: got %v", got)
+	}
+	for _, rs := range pr.Reviews {
+		if rs.AssignedAt.IsZero() || rs.SubmittedAt != nil {
+			t.Fatalf("pending review timestamps: got %+v", rs)
+		}
+	}
+
+	at := time.Now().UTC().Truncate(time.Microsecond)
+	if err := r.SetReviewState(ctx, "pr-1", "u2", model.ReviewApproved, at); err != nil {
+		t.Fatalf("SetReviewState: %v", err)
+	}
+	if err := r.SetReviewState(ctx, "pr-1", "u4", model.ReviewApproved, at); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("SetReviewState for unassigned user: got %v, want sql.ErrNoRows", err)
+	}
+
+	if err := r.SetPRReviewers(ctx, "pr-1", []string{"u2", "u4"}); err != nil {
+		t.Fatalf("SetPRReviewers: %v", err)
+	}
+	pr, err = r.GetPullRequestWithReviewers(ctx, "pr-1")
+	if err != nil {
+		t.Fatalf("GetPullRequestWithReviewers: %v", err)
+	}
+	want = map[string]model.ReviewState{"u2": model.ReviewApproved, "u4": model.ReviewPending}
+	if got := reviewStates(pr); !reflect.DeepEqual(got, want) {
+		t.Fatalf("SetPRReviewers must keep decisions of remaining reviewers: got %v", got)
+	}
+	for _, rs := range pr.Reviews {
+		if rs.UserID == "u2" && (rs.SubmittedAt == nil || !rs.SubmittedAt.Equal(at)) {
+			t.Fatalf("submitted_at: got %v, want %v", rs.SubmittedAt, at)
+		}
+	}
+}
+
+func testReviewerOrigin(t *testing.T, r service.Repo) {
+	ctx := context.Background()
+	mustCreateTeam(t, r, "backend", member("u1", true), member("u2", true))
+	mustCreateTeam(t, r, "platform", member("p1", true), member("p2", true))
+	mustCreatePR(t, r, "pr-1", "u1", "u2", "p1")
+
+	origin := model.ReviewerOrigin{
+		MatchReason:  model.MatchTag,
+		MatchedTags:  []string{"postgres", "sql"},
+		FallbackTeam: "platform",
+		OwnerPattern: "/db/",
+	}
+	if err := r.SetReviewerOrigin(ctx, "pr-1", "p1", origin); err != nil {
+		t.Fatalf("SetReviewerOrigin: %v", err)
+	}
+	if err := r.SetReviewerOrigin(ctx, "pr-1", "p2", origin); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("SetReviewerOrigin for unassigned user: got %v, want sql.ErrNoRows", err)
+	}
+
+	if err := r.SetPRReviewers(ctx, "pr-1", []string{"p1", "p2"}); err != nil {
+		t.Fatalf("SetPRReviewers: %v", err)
+	}
+	pr, err := r.GetPullRequestWithReviewers(ctx, "pr-1")
+	if err != nil {
+		t.Fatalf("GetPullRequestWithReviewers: %v", err)
+	}
+	got := map[string]model.ReviewerOrigin{}
+	for _, rs := range pr.Reviews {
+		got[rs.UserID] = rs.ReviewerOrigin
+	}
+	want := map[string]model.ReviewerOrigin{"p1": origin, "p2": {}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("origins must survive SetPRReviewers: got %v, want %v", got, want)
+	}
+}
+
+func testPullRequestsByReviewer(t *testing.T, r service.Repo) {
+	ctx := context.Background()
+	mustCreateTeam(t, r, "backend", member("u1", true), member("u2", true), member("u3", true))
+	mustCreatePR(t, r, "pr-1", "u1", "u2")
+	mustCreatePR(t, r, "pr-2", "u1", "u2", "u3")
+	mustCreatePR(t, r, "pr-3", "u1", "u3")
+	mustMerge(t, r, "pr-2")
+
+	list, err := r.GetPullRequestsByReviewer(ctx, "u2")
+	if err != nil {
+		t.Fatalf("GetPullRequestsByReviewer: %v", err)
+	}
+	got := map[string]model.PullRequestStatus{}
+	for _, p := range list {
+		got[p.ID] = p.Status
+	}
+	want := map[string]model.PullRequestStatus{"pr-1": model.PRStatusOpen, "pr-2": model.PRStatusMerged}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("GetPullRequestsByReviewer(u2): got %v, want %v", got, want)
+	}
+
+	list, err = r.GetPullRequestsByReviewer(ctx, "nobody")
+	if err != nil {
+		t.Fatalf("GetPullRequestsByReviewer: %v", err)
+	}
+	if list == nil || len(list) != 0 {
+		t.Fatalf("GetPullRequestsByReviewer(nobody): got %#v, want empty non-nil slice", list)
+	}
+}
+
+func listIDs(list []model.PullRequestShort) []string {
+	out := []string{}
+	for _, p := range list {
+		out = append(out, p.ID)
+	}
+	return out
+}
+
+func testListPullRequests(t *testing.T, r service.Repo) {
+	ctx := context.Background()
+	mustCreateTeam(t, r, "backend", member("u1", true), member("u2", true), member("u3", true))
+	mustCreateTeam(t, r, "frontend", member("f1", true))
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(h int) *time.Time {
+		v := base.Add(time.Duration(h) * time.Hour)
+		return &v
+	}
+	prs := []struct {
+		id, author string
+		status     model.PullRequestStatus
+		created    int
+		reviewers  []string
+	}{
+		{"pr-1", "u1", model.PRStatusOpen, 0, []string{"u2"}},
+		{"pr-2", "u1", model.PRStatusOpen, 1, []string{"u3"}},
+		{"pr-3", "f1", model.PRStatusOpen, 1, []string{"u2"}},
+		{"pr-4", "u2", model.PRStatusDraft, 2, nil},
+		{"pr-5", "u1", model.PRStatusOpen, 3, []string{"u2", "u3"}},
+	}
+	for _, p := range prs {
+		err := r.CreatePullRequest(ctx, model.PullRequest{
+			ID: p.id, Name: "PR " + p.id, AuthorID: p.author, Status: p.status,
+			AssignedReviewers: p.reviewers, CreatedAt: at(p.created),
+		})
+		if err != nil {
+			t.Fatalf("CreatePullRequest(%s): %v", p.id, err)
+		}
+	}
+	for id, h := range map[string]int{"pr-2": 5, "pr-5": 4} {
+		if _, err := r.SetPRMerged(ctx, id, sql.NullTime{Time: *at(h), Valid: true}, false); err != nil {
+			t.Fatalf("SetPRMerged(%s): %v", id, err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		f    model.PullRequestFilter
+		want []string
+	}{
+		{"newest first", model.PullRequestFilter{Sort: model.SortCreatedDesc},
+			[]string{"pr-5", "pr-4", "pr-3", "pr-2", "pr-1"}},
+		{"first page", model.PullRequestFilter{Sort: model.SortCreatedAsc, Limit: 2},
+			[]string{"pr-1", "pr-2"}},
+		{"after tie", model.PullRequestFilter{Sort: model.SortCreatedAsc, Limit: 2,
+			After: &model.PullRequestCursor{At: *at(1), ID: "pr-2"}},
+			[]string{"pr-3", "pr-4"}},
+		{"after tie desc", model.PullRequestFilter{Sort: model.SortCreatedDesc,
+			After: &model.PullRequestCursor{At: *at(1), ID: "pr-3"}},
+			[]string{"pr-2", "pr-1"}},
+		{"merged only", model.PullRequestFilter{Sort: model.SortMergedAsc},
+			[]string{"pr-5", "pr-2"}},
+		{"merged desc", model.PullRequestFilter{Sort: model.SortMergedDesc, Statuses: []model.PullRequestStatus{model.PRStatusMerged}},
+			[]string{"pr-2", "pr-5"}},
+		{"statuses", model.PullRequestFilter{Sort: model.SortCreatedAsc,
+			Statuses: []model.PullRequestStatus{model.PRStatusOpen, model.PRStatusDraft}},
+			[]string{"pr-1", "pr-3", "pr-4"}},
+		{"author", model.PullRequestFilter{Sort: model.SortCreatedAsc, AuthorID: "u1"},
+			[]string{"pr-1", "pr-2", "pr-5"}},
+		{"reviewer and team", model.PullRequestFilter{Sort: model.SortCreatedAsc, ReviewerID: "u2", Team: "backend"},
+			[]string{"pr-1", "pr-5"}},
+		{"created range", model.PullRequestFilter{Sort: model.SortCreatedAsc, CreatedFrom: at(1), CreatedTo: at(3)},
+			[]string{"pr-2", "pr-3", "pr-4"}},
+		{"merged range", model.PullRequestFilter{Sort: model.SortCreatedAsc, MergedFrom: at(4), MergedTo: at(5)},
+			[]string{"pr-5"}},
+		{"empty", model.PullRequestFilter{Sort: model.SortCreatedAsc, AuthorID: "nobody"},
+			[]string{}},
+	}
+	for _, tc := range tests {
+		if tc.f.Limit == 0 {
+			tc.f.Limit = 10
+		}
+		list, err := r.ListPullRequests(ctx, tc.f)
+		if err != nil {
+			t.Fatalf("ListPullRequests(%s): %v", tc.name, err)
+		}
+		if got := listIDs(list); !reflect.DeepEqual(got, tc.want) {
+			t.Fatalf("ListPullRequests(%s): got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+
+	list, err := r.ListPullRequests(ctx, model.PullRequestFilter{Sort: model.SortMergedAsc, Limit: 1})
+	if err != nil {
+		t.Fatalf("ListPullRequests: %v", err)
+	}
+	p := list[0]
+	if p.Status != model.PRStatusMerged || p.CreatedAt == nil || !p.CreatedAt.Equal(*at(3)) ||
+		p.MergedAt == nil || !p.MergedAt.Equal(*at(4)) {
+		t.Fatalf("ListPullRequests: got %+v, want merged pr-5 with createdAt and mergedAt", p)
+	}
+}
+
+func testUnderstaffedPullRequests(t *testing.T, r service.Repo) {
+	ctx := context.Background()
+	mustCreateTeam(t, r, "backend", member("u1", true), member("u2", true), member("u3", true))
+	mustCreateTeam(t, r, "frontend", member("f1", true))
+	mustCreatePR(t, r, "pr-1", "u1", "u2")
+	mustCreatePR(t, r, "pr-2", "u1", "u2", "u3")
+	mustCreatePR(t, r, "pr-3", "u1")
+	mustCreatePR(t, r, "pr-4", "u1", "u2")
+	mustCreatePR(t, r, "pr-5", "f1")
+	mustMerge(t, r, "pr-4")
+
+	ids, err := r.GetUnderstaffedPullRequests(ctx, "backend", 2)
+	if err != nil {
+		t.Fatalf("GetUnderstaffedPullRequests: %v", err)
+	}
+	if want := []string{"pr-1", "pr-3"}; !reflect.DeepEqual(ids, want) {
+		t.Fatalf("GetUnderstaffedPullRequests(backend, 2): got %v, want %v", ids, want)
+	}
+
+	ids, err = r.GetUnderstaffedPullRequests(ctx, "backend", 0)
+	if err != nil {
+		t.Fatalf("GetUnderstaffedPullRequests: %v", err)
+	}
+	if ids == nil || len(ids) != 0 {
+		t.Fatalf("GetUnderstaffedPullRequests(backend, 0): got %#v, want empty non-nil slice", ids)
+	}
+}
+
+func testRecentReviewers(t *testing.T, r service.Repo) {
+	ctx := context.Background()
+	mustCreateTeam(t, r, "backend", member("u1", true), member("u2", true), member("u3", true), member("u4", true))
+	mustCreatePR(t, r, "pr-1", "u1", "u2")
+	mustCreatePR(t, r, "pr-2", "u1", "u4", "u3")
+	mustCreatePR(t, r, "pr-3", "u1")
+	mustCreatePR(t, r, "pr-4", "u1", "u2")
+	mustCreatePR(t, r, "pr-5", "u2", "u1")
+
+	recent, err := r.GetRecentReviewers(ctx, "u1", 2)
+	if err != nil {
+		t.Fatalf("GetRecentReviewers: %v", err)
+	}
+	// pr-3 без ревьюверов не занимает место в окне.
+	if want := [][]string{{"u2"}, {"u3", "u4"}}; !reflect.DeepEqual(recent, want) {
+		t.Fatalf("GetRecentReviewers(u1, 2): got %v, want %v", recent, want)
+	}
+
+	recent, err = r.GetRecentReviewers(ctx, "u3", 5)
+	if err != nil {
+		t.Fatalf("GetRecentReviewers: %v", err)
+	}
+	if recent == nil || len(recent) != 0 {
+		t.Fatalf("GetRecentReviewers(u3): got %#v, want empty non-nil slice", recent)
+	}
+}
+
+func testAssignments(t *testing.T, r service.Repo) {
+	ctx := context.Background()
+	mustCreateTeam(t, r, "backend", member("u1", true), member("u2", true), member("o'brien", true))
+	mustCreatePR(t, r, "pr-1", "u1", "u2")
+
+	now := time.Now().UTC().Truncate(time.Second)
+	first := model.Assignment{
+		PullRequestID: "pr-1",
+		Kind:          model.AssignmentCreate,
+		Seed:          -42,
+		Strategy:      "random",
+		Excluded:      []string{"u1"},
+		Steps: []model.AssignmentStep{{
+			Reason:     model.MatchTeam,
+			Team:       "backend",
+			Strategy:   "random",
+			Pool:       []string{"o'brien", "u2"},
+			AtCapacity: []string{},
+			Chosen:     []string{"u2"},
+		}},
+		Chosen:    []string{"u2"},
+		CreatedAt: now,
+	}
+	second := model.Assignment{
+		PullRequestID: "pr-1",
+		Kind:          model.AssignmentReassign,
+		Seed:          7,
+		Strategy:      "least_loaded",
+		Excluded:      []string{"u2", "u1"},
+		Steps:         []model.AssignmentStep{},
+		Chosen:        []string{"o'brien"},
+		CreatedAt:     now.Add(time.Minute),
+	}
+
+	var created []*model.Assignment
+	for _, a := range []model.Assignment{first, second} {
+		got, err := r.CreateAssignment(ctx, a)
+		if err != nil {
+			t.Fatalf("CreateAssignment: %v", err)
+		}
+		if got.ID == 0 {
+			t.Fatalf("CreateAssignment must assign an ID")
+		}
+		created = append(created, got)
+	}
+	if created[1].ID <= created[0].ID {
+		t.Fatalf("assignment IDs must grow: got %d then %d", created[0].ID, created[1].ID)
+	}
+
+	list, err := r.ListAssignments(ctx, "pr-1")
+	if err != nil {
+		t.Fatalf("ListAssignments: %v", err)
+	}
+	for i := range list {
+		list[i].CreatedAt = list[i].CreatedAt.UTC()
+	}
+	if want := []model.Assignment{*created[0], *created[1]}; !reflect.DeepEqual(list, want) {
+		t.Fatalf("ListAssignments: got %+v, want %+v", list, want)
+	}
+
+	if list, err := r.ListAssignments(ctx, "pr-missing"); err != nil || len(list) != 0 {
+		t.Fatalf("ListAssignments for unknown PR: got %+v, %v", list, err)
+	}
+	if _, err := r.CreateAssignment(ctx, model.Assignment{PullRequestID: "pr-missing", CreatedAt: now}); err == nil {
+		t.Fatalf("CreateAssignment for unknown PR must fail")
+	}
+}
+
+func testStatsOrdering(t *testing.T, r service.Repo) {
+	ctx := context.Background()
+	mustCreateTeam(t, r, "backend", member("u1", true), member("u2", true), member("u3", true), member("u4", true))
+	mustCreatePR(t, r, "pr-1", "u1", "u2", "u3")
+	mustCreatePR(t, r, "pr-2", "u1", "u3")
+	mustCreatePR(t, r, "pr-3", "u4", "u3", "u2")
+	mustMerge(t, r, "pr-1")
+
+	stats, err := r.GetReviewerAssignmentStats(ctx)
+	if err != nil {
+		t.Fatalf("GetReviewerAssignmentStats: %v", err)
+	}
+	if len(stats) != 2 {
+		t.Fatalf("stats: got %+v, want two reviewers", stats)
+	}
+	want := []model.ReviewerStat{
+		{UserID: "u3", Assignments: 3, OpenAssignments: 2},
+		{UserID: "u2", Assignments: 2, OpenAssignments: 1},
+	}
+	if !reflect.DeepEqual(stats, want) {
+		t.Fatalf("stats must be ordered by assignments desc: got %+v, want %+v", stats, want)
+	}
+}
+
+func testCandidatesFiltering(t *testing.T, r service.Repo) {
+	ctx := context.Background()
+	mustCreateTeam(t, r, "backend", member("u1", true), member("u2", false), member("u3", true), member("u4", true))
+	mustCreateTeam(t, r, "frontend", member("f1", true))
+
+	cands, err := r.GetReviewCandidates(ctx, "backend", []string{"u4"})
+	if err != nil {
+		t.Fatalf("GetReviewCandidates: %v", err)
+	}
+	if got := candidateIDs(cands); !reflect.DeepEqual(got, []string{"u1", "u3"}) {
+		t.Fatalf("GetReviewCandidates: got %v, want [u1 u3] in user_id order", got)
+	}
+
+	tags := []string{"postgres"}
+	if _, err := r.UpdateUser(ctx, "u3", model.UserUpdate{Tags: &tags}); err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	cands, err = r.GetReviewCandidates(ctx, "backend", nil)
+	if err != nil {
+		t.Fatalf("GetReviewCandidates: %v", err)
+	}
+	if len(cands) != 3 || !reflect.DeepEqual(cands[1].Tags, tags) || len(cands[0].Tags) != 0 {
+		t.Fatalf("GetReviewCandidates must report tags: got %+v", cands)
+	}
+
+	for _, exclude := range [][]string{nil, {}} {
+		cands, err := r.GetReviewCandidates(ctx, "backend", exclude)
+		if err != nil {
+			t.Fatalf("GetReviewCandidates(%#v): %v", exclude, err)
+		}
+		if got := candidateIDs(cands); !reflect.DeepEqual(got, []string{"u1", "u3", "u4"}) {
+			t.Fatalf("candidates with exclude %#v: got %v", exclude, got)
+		}
+	}
+}
+
+func testCandidateCapacity(t *testing.T, r service.Repo) {
+	ctx := context.Background()
+	busy := member("u2", true)
+	busy.MaxOpenReviews = 1
+	mustCreateTeam(t, r, "backend", member("u1", true), busy, member("u3", true))
+	mustCreatePR(t, r, "pr-1", "u1", "u2")
+
+	cands, err := r.GetReviewCandidates(ctx, "backend", []string{"u1"})
+	if err != nil {
+		t.Fatalf("GetReviewCandidates: %v", err)
+	}
+	want := []model.ReviewCandidate{
+		{UserID: "u2", OpenReviews: 1, MaxOpenReviews: 1, Tags: []string{}, Level: model.DefaultLevel},
+		{UserID: "u3", OpenReviews: 0, MaxOpenReviews: 0, Tags: []string{}, Level: model.DefaultLevel},
+	}
+	if !reflect.DeepEqual(cands, want) {
+		t.Fatalf("GetReviewCandidates must report load without filtering: got %+v, want %+v", cands, want)
+	}
+
+	// После merge ревью перестаёт считаться открытым.
+	mustMerge(t, r, "pr-1")
+	cands, err = r.GetReviewCandidates(ctx, "backend", []string{"u1"})
+	if err != nil {
+		t.Fatalf("GetReviewCandidates: %v", err)
+	}
+	if len(cands) != 2 || cands[0].OpenReviews != 0 || !cands[0].HasCapacity() {
+		t.Fatalf("merged reviews must not count towards capacity: got %+v", cands)
+	}
+}
+
+func testOpenReviewCounts(t *testing.T, r service.Repo) {
+	ctx := context.Background()
+	mustCreateTeam(t, r, "backend", member("u1", true), member("u2", true), member("u3", true), member("u4", true))
+	mustCreatePR(t, r, "pr-1", "u1", "u2", "u3")
+	mustCreatePR(t, r, "pr-2", "u1", "u2")
+	mustCreatePR(t, r, "pr-3", "u4", "u3")
+	mustMerge(t, r, "pr-3")
+
+	// Открытые ревью: u2=2, u3=1, u4=0 (ревью u3 в pr-3 уже не открыто).
+	cands, err := r.GetReviewCandidates(ctx, "backend", []string{"u1"})
+	if err != nil {
+		t.Fatalf("GetReviewCandidates: %v", err)
+	}
+	load := map[string]int{}
+	for _, c := range cands {
+		load[c.UserID] = c.OpenReviews
+	}
+	if want := map[string]int{"u2": 2, "u3": 1, "u4": 0}; !reflect.DeepEqual(load, want) {
+		t.Fatalf("open reviews: got %v, want %v", load, want)
+	}
+}
+
+func testHostileUserIDs(t *testing.T, r service.Repo) {
+	ctx := context.Background()
+	hostile := []string{
+		"o'brien",
+		"x') OR ('1'='1",
+		"'; DROP TABLE users; --",
+		`back\slash"quote`,
+		`\`,
+		`"`,
+		"{braces,comma}",
+	}
+
+	members := []model.TeamMember{member("plain", true)}
+	for _, id := range hostile {
+		members = append(members, member(id, true))
+	}
+	mustCreateTeam(t, r, "backend", members...)
+
+	for _, id := range hostile {
+		u, err := r.GetUserByID(ctx, id)
+		if err != nil || u.UserID != id {
+			t.Fatalf("GetUserByID(%q): got %+v, %v", id, u, err)
+		}
+	}
+
+	cands, err := r.GetReviewCandidates(ctx, "backend", hostile)
+	if err != nil {
+		t.Fatalf("GetReviewCandidates with hostile exclude: %v", err)
+	}
+	if got := candidateIDs(cands); !reflect.DeepEqual(got, []string{"plain"}) {
+		t.Fatalf("hostile IDs must be excluded literally: got %v", got)
+	}
+
+	cands, err = r.GetReviewCandidates(ctx, "backend", []string{"plain"})
+	if err != nil {
+		t.Fatalf("GetReviewCandidates: %v", err)
+	}
+	if got := sorted(candidateIDs(cands)); !reflect.DeepEqual(got, sorted(hostile)) {
+		t.Fatalf("GetReviewCandidates with hostile IDs: got %v", got)
+	}
+
+	for _, exclude := range [][]string{nil, {}} {
+		cands, err = r.GetReviewCandidates(ctx, "backend", exclude)
+		if err != nil {
+			t.Fatalf("GetReviewCandidates with exclude %#v: %v", exclude, err)
+		}
+		if got, want := sorted(candidateIDs(cands)), sorted(append([]string{"plain"}, hostile...)); !reflect.DeepEqual(got, want) {
+			t.Fatalf("GetReviewCandidates with exclude %#v: got %v, want %v", exclude, got, want)
+		}
+	}
+
+	mustCreatePR(t, r, "pr-'1", hostile[0], hostile[1], hostile[2])
+	list, err := r.GetPullRequestsByReviewer(ctx, hostile[1])
+	if err != nil || len(list) != 1 || list[0].ID != "pr-'1" {
+		t.Fatalf("GetPullRequestsByReviewer(%q): got %+v, %v", hostile[1], list, err)
+	}
+}
+
+func testInTx(t *testing.T, r service.Repo) {
+	ctx := context.Background()
+	mustCreateTeam(t, r, "backend", member("u1", true), member("u2", true))
+
+	errBoom := errors.New("boom")
+	err := r.InTx(ctx, func(ctx context.Context) error {
+		if _, err := r.UpdateUserIsActive(ctx, "u1", false); err != nil {
+			return err
+		}
+		mustCreatePRCtx(t, ctx, r, "pr-1", "u1", "u2")
+		return errBoom
+	})
+	if !errors.Is(err, errBoom) {
+		t.Fatalf("InTx must return fn error: got %v", err)
+	}
+
+	u, err := r.GetUserByID(ctx, "u1")
+	if err != nil {
+		t.Fatalf("GetUserByID: %v", err)
+	}
+	if !u.IsActive {
+		t.Fatalf("InTx must roll back user update on error")
+	}
+	if exists, _ := r.PRExists(ctx, "pr-1"); exists {
+		t.Fatalf("InTx must roll back PR creation on error")
+	}
+
+	err = r.InTx(ctx, func(ctx context.Context) error {
+		if _, err := r.UpdateUserIsActive(ctx, "u1", false); err != nil {
+			return err
+		}
+		// Вложенный InTx работает во внешней транзакции.
+		return r.InTx(ctx, func(ctx context.Context) error {
+			mustCreatePRCtx(t, ctx, r, "pr-1", "u1", "u2")
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatalf("InTx: %v", err)
+	}
+
+	u, err = r.GetUserByID(ctx, "u1")
+	if err != nil {
+		t.Fatalf("GetUserByID: %v", err)
+	}
+	if u.IsActive {
+		t.Fatalf("InTx must commit user update")
+	}
+	if exists, _ := r.PRExists(ctx, "pr-1"); !exists {
+		t.Fatalf("InTx must commit PR creation")
+	}
+}
+
+func mustCreatePRCtx(t *testing.T, ctx context.Context, r service.Repo, id, author string, reviewers ...string) {
+	t.Helper()
+	now := time.Now().UTC()
+	err := r.CreatePullRequest(ctx, model.PullRequest{
+		ID:                id,
+		Name:              "PR " + id,
+		AuthorID:          author,
+		AssignedReviewers: reviewers,
+		CreatedAt:         &now,
+	})
+	if err != nil {
+		t.Fatalf("CreatePullRequest(%s): %v", id, err)
+	}
+}
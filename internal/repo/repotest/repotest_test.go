@@ -0,0 +1,20 @@
+package repotest_test
+
+import (
+	"testing"
+
+	"pr-review-service/internal/repo/repotest"
+)
+
+func TestMemoryRepo(t *testing.T) {
+	repotest.Run(t, repotest.Memory)
+}
+
+func TestSQLiteRepo(t *testing.T) {
+	repotest.Run(t, repotest.SQLite)
+}
+
+// TestPostgresRepo пропускается без TEST_DATABASE_DSN.
+func TestPostgresRepo(t *testing.T) {
+	repotest.Run(t, repotest.Postgres)
+}
@@ -0,0 +1,949 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"pr-review-service/internal/model"
+)
+
+/*
+SQLiteRepo реализует слой доступа к данным поверх SQLite для запуска
+сервиса одним бинарником без PostgreSQL.
+
+Поведение совпадает с PostgresRepo. Отличия СУБД спрятаны внутри:
+списки user_id передаются JSON-массивом через json_each,
+а время хранится текстом (см. sqliteTimeLayout).
+*/
+type SQLiteRepo struct {
+	sqlConn
+}
+
+func NewSQLiteRepo(db *sql.DB) *SQLiteRepo {
+	return &SQLiteRepo{sqlConn{db: db}}
+}
+
+// sqliteTimeLayout — формат фиксированной ширины в UTC: строковое сравнение
+// таких значений совпадает с хронологическим.
+const sqliteTimeLayout = "2006-01-02T15:04:05.000000000Z"
+
+func sqliteTime(t time.Time) string {
+	return t.UTC().Format(sqliteTimeLayout)
+}
+
+func parseSQLiteTime(s string) (time.Time, error) {
+	return time.Parse(sqliteTimeLayout, s)
+}
+
+// parseSQLiteNullTime разбирает NULL-able колонку времени в указатель.
+func parseSQLiteNullTime(s sql.NullString) (*time.Time, error) {
+	if !s.Valid {
+		return nil, nil
+	}
+	t, err := parseSQLiteTime(s.String)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// jsonIDs кодирует список user_id для json_each; nil превращается в [].
+func jsonIDs(ids []string) string {
+	if ids == nil {
+		ids = []string{}
+	}
+	b, _ := json.Marshal(ids)
+	return string(b)
+}
+
+// sqliteOpenReviewsQuery считает ревью каждого пользователя в открытых PR.
+const sqliteOpenReviewsQuery = `
+	SELECT r.user_id, COUNT(*) AS open_reviews
+	FROM pull_request_reviewers r
+	JOIN pull_requests pr ON pr.pull_request_id = r.pull_request_id
+	WHERE pr.status = 'OPEN'
+	GROUP BY r.user_id`
+
+/*
+CreateTeamWithMembers создаёт команду и всех её участников
+в рамках одной транзакции.
+*/
+func (r *SQLiteRepo) CreateTeamWithMembers(ctx context.Context, t model.Team) error {
+	tx, err := r.beginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var exists bool
+	err = tx.QueryRowContext(ctx,
+		"SELECT EXISTS (SELECT 1 FROM teams WHERE name=?)", t.TeamName,
+	).Scan(&exists)
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		return errors.New("team_exists")
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO teams(name) VALUES (?)", t.TeamName)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range t.Members {
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO users(user_id, username, team_name, is_active, max_open_reviews, tags, level)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT (user_id) DO UPDATE
+				SET username = excluded.username,
+					team_name = excluded.team_name,
+					is_active = excluded.is_active,
+					max_open_reviews = excluded.max_open_reviews,
+					tags = excluded.tags,
+					level = excluded.level
+		`, m.UserID, m.Username, t.TeamName, m.IsActive, m.MaxOpenReviews, strings.Join(m.Tags, " "), levelArg(m.Level))
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+/*
+GetTeam возвращает команду и всех её участников.
+*/
+func (r *SQLiteRepo) GetTeam(ctx context.Context, name string) (*model.Team, error) {
+	var tn string
+	err := r.conn(ctx).QueryRowContext(ctx, "SELECT name FROM teams WHERE name=?", name).Scan(&tn)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.conn(ctx).QueryContext(ctx, `
+		SELECT user_id, username, is_active, max_open_reviews, tags, level
+		FROM users
+		WHERE team_name=?
+		ORDER BY user_id
+	`, name)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	team := model.Team{TeamName: tn, Members: []model.TeamMember{}}
+	for rows.Next() {
+		var m model.TeamMember
+		var tags string
+		if err := rows.Scan(&m.UserID, &m.Username, &m.IsActive, &m.MaxOpenReviews, &tags, &m.Level); err != nil {
+			return nil, err
+		}
+		m.Tags = strings.Fields(tags)
+		team.Members = append(team.Members, m)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &team, nil
+}
+
+/*
+GetTeamSettings возвращает настройки команды вместе с резервными командами.
+Возвращает sql.ErrNoRows, если команды нет.
+*/
+func (r *SQLiteRepo) GetTeamSettings(ctx context.Context, name string) (*model.TeamSettings, error) {
+	row := r.conn(ctx).QueryRowContext(ctx, `
+		SELECT `+teamSettingsColumns+`
+		FROM teams
+		WHERE name=?
+	`, name)
+
+	ts, err := scanTeamSettings(row)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.conn(ctx).QueryContext(ctx, `
+		SELECT fallback_team
+		FROM team_fallbacks
+		WHERE team_name=?
+		ORDER BY position
+	`, name)
+	if err != nil {
+		return nil, err
+	}
+	ts.FallbackTeams, err = scanIDs(rows)
+	if err != nil {
+		return nil, err
+	}
+	return ts, nil
+}
+
+/*
+UpdateTeamSettings сохраняет настройки команды и заменяет список
+резервных команд. Возвращает sql.ErrNoRows, если команды нет.
+*/
+func (r *SQLiteRepo) UpdateTeamSettings(ctx context.Context, ts model.TeamSettings) error {
+	tx, err := r.beginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.ExecContext(ctx, `
+		UPDATE teams
+		SET auto_reassign_on_deactivate=?, min_approvals=?, block_on_changes_requested=?,
+			reviewer_count=?, min_reviewers=?, max_reviewers=?, rotation_window=?, require_senior=?
+		WHERE name=?
+	`, append(teamSettingsArgs(ts), ts.TeamName)...)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM team_fallbacks WHERE team_name=?`, ts.TeamName); err != nil {
+		return err
+	}
+	for i, fb := range ts.FallbackTeams {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO team_fallbacks(team_name, fallback_team, position)
+			VALUES (?, ?, ?)
+		`, ts.TeamName, fb, i)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+/*
+GetOwnershipRules возвращает правила CODEOWNERS команды в порядке объявления.
+Для команды без правил возвращается пустой список.
+*/
+func (r *SQLiteRepo) GetOwnershipRules(ctx context.Context, team string) ([]model.OwnershipRule, error) {
+	rows, err := r.conn(ctx).QueryContext(ctx, `
+		SELECT pattern, owners
+		FROM team_codeowners
+		WHERE team_name=?
+		ORDER BY position
+	`, team)
+	if err != nil {
+		return nil, err
+	}
+	return scanOwnershipRules(rows)
+}
+
+/*
+SetOwnershipRules заменяет правила CODEOWNERS команды.
+Возвращает sql.ErrNoRows, если команды нет.
+*/
+func (r *SQLiteRepo) SetOwnershipRules(ctx context.Context, team string, rules []model.OwnershipRule) error {
+	tx, err := r.beginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var exists bool
+	err = tx.QueryRowContext(ctx,
+		"SELECT EXISTS (SELECT 1 FROM teams WHERE name=?)", team,
+	).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return sql.ErrNoRows
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM team_codeowners WHERE team_name=?`, team); err != nil {
+		return err
+	}
+	for i, rule := range rules {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO team_codeowners(team_name, position, pattern, owners)
+			VALUES (?, ?, ?, ?)
+		`, team, i, rule.Pattern, strings.Join(rule.Owners, " "))
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+/*
+GetUserByID возвращает пользователя по идентификатору.
+*/
+func (r *SQLiteRepo) GetUserByID(ctx context.Context, id string) (*model.User, error) {
+	row := r.conn(ctx).QueryRowContext(ctx, `
+		SELECT `+userColumns+`
+		FROM users
+		WHERE user_id=?
+	`, id)
+	return scanUser(row)
+}
+
+/*
+UpdateUserIsActive обновляет флаг активности пользователя.
+*/
+func (r *SQLiteRepo) UpdateUserIsActive(ctx context.Context, id string, active bool) (*model.User, error) {
+	row := r.conn(ctx).QueryRowContext(ctx, `
+		UPDATE users SET is_active=? WHERE user_id=?
+		RETURNING `+userColumns+`
+	`, active, id)
+	return scanUser(row)
+}
+
+/*
+UpdateUser частично обновляет пользователя: поля со значением nil не меняются.
+*/
+func (r *SQLiteRepo) UpdateUser(ctx context.Context, id string, upd model.UserUpdate) (*model.User, error) {
+	row := r.conn(ctx).QueryRowContext(ctx, `
+		UPDATE users
+		SET username = COALESCE(?, username),
+			max_open_reviews = COALESCE(?, max_open_reviews),
+			tags = COALESCE(?, tags),
+			level = COALESCE(?, level)
+		WHERE user_id=?
+		RETURNING `+userColumns+`
+	`, upd.Username, upd.MaxOpenReviews, tagsArg(upd.Tags), upd.Level, id)
+	return scanUser(row)
+}
+
+/*
+CreateUnavailability сохраняет окно недоступности пользователя.
+*/
+func (r *SQLiteRepo) CreateUnavailability(ctx context.Context, w model.UnavailabilityWindow) (*model.UnavailabilityWindow, error) {
+	err := r.conn(ctx).QueryRowContext(ctx, `
+		INSERT INTO user_unavailability(user_id, starts_at, ends_at, reason)
+		VALUES (?, ?, ?, ?)
+		RETURNING window_id
+	`, w.UserID, sqliteTime(w.StartsAt), sqliteTime(w.EndsAt), w.Reason).Scan(&w.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+/*
+ListUnavailability возвращает окна недоступности пользователя,
+которые ещё не закончились, в порядке начала.
+*/
+func (r *SQLiteRepo) ListUnavailability(ctx context.Context, uid string) ([]model.UnavailabilityWindow, error) {
+	rows, err := r.conn(ctx).QueryContext(ctx, `
+		SELECT window_id, user_id, starts_at, ends_at, reason
+		FROM user_unavailability
+		WHERE user_id=? AND ends_at > ?
+		ORDER BY starts_at, window_id
+	`, uid, sqliteTime(time.Now()))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	result := []model.UnavailabilityWindow{}
+	for rows.Next() {
+		var w model.UnavailabilityWindow
+		var starts, ends string
+		if err := rows.Scan(&w.ID, &w.UserID, &starts, &ends, &w.Reason); err != nil {
+			return nil, err
+		}
+		if w.StartsAt, err = parseSQLiteTime(starts); err != nil {
+			return nil, err
+		}
+		if w.EndsAt, err = parseSQLiteTime(ends); err != nil {
+			return nil, err
+		}
+		result = append(result, w)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+/*
+DeleteUnavailability удаляет окно недоступности.
+Возвращает sql.ErrNoRows, если окна с таким ID нет.
+*/
+func (r *SQLiteRepo) DeleteUnavailability(ctx context.Context, id int64) error {
+	res, err := r.conn(ctx).ExecContext(ctx,
+		`DELETE FROM user_unavailability WHERE window_id=?`, id)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+/*
+PRExists проверяет, существует ли Pull Request с указанным ID.
+*/
+func (r *SQLiteRepo) PRExists(ctx context.Context, id string) (bool, error) {
+	var exists bool
+	err := r.conn(ctx).QueryRowContext(ctx,
+		"SELECT EXISTS (SELECT 1 FROM pull_requests WHERE pull_request_id=?)", id,
+	).Scan(&exists)
+	return exists, err
+}
+
+/*
+CreatePullRequest создаёт новый PR и всех его ревьюверов.
+*/
+func (r *SQLiteRepo) CreatePullRequest(ctx context.Context, pr model.PullRequest) error {
+	tx, err := r.beginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	created := time.Now()
+	if pr.CreatedAt != nil {
+		created = *pr.CreatedAt
+	}
+
+	status := pr.Status
+	if status == "" {
+		status = model.PRStatusOpen
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO pull_requests(pull_request_id, pull_request_name, author_id, status, created_at, labels)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, pr.ID, pr.Name, pr.AuthorID, status, sqliteTime(created), strings.Join(pr.Labels, " "))
+	if err != nil {
+		return err
+	}
+
+	for _, rID := range pr.AssignedReviewers {
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO pull_request_reviewers(pull_request_id, user_id, assigned_at)
+			VALUES (?, ?, ?)
+		`, pr.ID, rID, sqliteTime(created))
+		if err != nil {
+			return err
+		}
+	}
+
+	for i, path := range pr.ChangedPaths {
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO pull_request_paths(pull_request_id, position, path)
+			VALUES (?, ?, ?)
+		`, pr.ID, i, path)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+/*
+GetPullRequestWithReviewers возвращает полный объект PR
+вместе со списком его ревьюверов.
+*/
+func (r *SQLiteRepo) GetPullRequestWithReviewers(ctx context.Context, id string) (*model.PullRequest, error) {
+	row := r.conn(ctx).QueryRowContext(ctx, `
+		SELECT pull_request_id, pull_request_name, author_id, status, created_at, merged_at, closed_at, merge_override, labels
+		FROM pull_requests
+		WHERE pull_request_id=?
+	`, id)
+
+	var pr model.PullRequest
+	var created, labels string
+	var merged, closed sql.NullString
+	err := row.Scan(&pr.ID, &pr.Name, &pr.AuthorID, &pr.Status, &created, &merged, &closed, &pr.MergeOverride, &labels)
+	if err != nil {
+		return nil, err
+	}
+	pr.Labels = strings.Fields(labels)
+	pr.AssignedReviewers = []string{}
+	pr.Reviews = []model.ReviewerState{}
+
+	createdAt, err := parseSQLiteTime(created)
+	if err != nil {
+		return nil, err
+	}
+	pr.CreatedAt = &createdAt
+	if pr.MergedAt, err = parseSQLiteNullTime(merged); err != nil {
+		return nil, err
+	}
+	if pr.ClosedAt, err = parseSQLiteNullTime(closed); err != nil {
+		return nil, err
+	}
+
+	revRows, err := r.conn(ctx).QueryContext(ctx, `
+		SELECT user_id, state, assigned_at, submitted_at, fallback_team, owner_pattern, match_reason, matched_tags
+		FROM pull_request_reviewers
+		WHERE pull_request_id=?
+		ORDER BY assigned_at, user_id
+	`, pr.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = revRows.Close() }()
+
+	for revRows.Next() {
+		var rs model.ReviewerState
+		var assigned, matched string
+		var submitted sql.NullString
+		if err := revRows.Scan(&rs.UserID, &rs.State, &assigned, &submitted,
+			&rs.FallbackTeam, &rs.OwnerPattern, &rs.MatchReason, &matched); err != nil {
+			return nil, err
+		}
+		if matched != "" {
+			rs.MatchedTags = strings.Fields(matched)
+		}
+		if rs.AssignedAt, err = parseSQLiteTime(assigned); err != nil {
+			return nil, err
+		}
+		if rs.SubmittedAt, err = parseSQLiteNullTime(submitted); err != nil {
+			return nil, err
+		}
+		pr.AssignedReviewers = append(pr.AssignedReviewers, rs.UserID)
+		pr.Reviews = append(pr.Reviews, rs)
+	}
+
+	if err := revRows.Err(); err != nil {
+		return nil, err
+	}
+
+	pathRows, err := r.conn(ctx).QueryContext(ctx, `
+		SELECT path FROM pull_request_paths WHERE pull_request_id=? ORDER BY position
+	`, pr.ID)
+	if err != nil {
+		return nil, err
+	}
+	if pr.ChangedPaths, err = scanIDs(pathRows); err != nil {
+		return nil, err
+	}
+
+	return &pr, nil
+}
+
+/*
+LockPullRequest берёт блокировку записи до конца транзакции InTx. В SQLite
+нет SELECT ... FOR UPDATE, поэтому строка PR обновляется без изменений.
+Возвращает sql.ErrNoRows, если PR нет.
+*/
+func (r *SQLiteRepo) LockPullRequest(ctx context.Context, id string) error {
+	res, err := r.conn(ctx).ExecContext(ctx, `
+		UPDATE pull_requests SET pull_request_id = pull_request_id WHERE pull_request_id=?
+	`, id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+/*
+SetPRMerged изменяет статус PR на MERGED и устанавливает merged_at.
+override отмечает мердж в обход политики одобрений.
+*/
+func (r *SQLiteRepo) SetPRMerged(ctx context.Context, id string, mergedAt sql.NullTime, override bool) (*model.PullRequest, error) {
+	var merged sql.NullString
+	if mergedAt.Valid {
+		merged = sql.NullString{String: sqliteTime(mergedAt.Time), Valid: true}
+	}
+
+	_, err := r.conn(ctx).ExecContext(ctx, `
+		UPDATE pull_requests
+		SET status='MERGED', merged_at=?, merge_override=?
+		WHERE pull_request_id=?
+	`, merged, override, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.GetPullRequestWithReviewers(ctx, id)
+}
+
+/*
+SetPRStatus меняет статус PR. closed_at выставляется в at для CLOSED
+и сбрасывается для остальных статусов. MERGED ставится через SetPRMerged.
+Возвращает sql.ErrNoRows, если PR нет.
+*/
+func (r *SQLiteRepo) SetPRStatus(ctx context.Context, id string, status model.PullRequestStatus, at time.Time) error {
+	var closedAt sql.NullString
+	if status == model.PRStatusClosed {
+		closedAt = sql.NullString{String: sqliteTime(at), Valid: true}
+	}
+
+	res, err := r.conn(ctx).ExecContext(ctx, `
+		UPDATE pull_requests
+		SET status=?, closed_at=?
+		WHERE pull_request_id=?
+	`, status, closedAt, id)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+/*
+SetPRReviewers заменяет список ревьюверов PR на новый.
+Решения оставшихся ревьюверов сохраняются, новые получают состояние PENDING.
+*/
+func (r *SQLiteRepo) SetPRReviewers(ctx context.Context, id string, reviewers []string) error {
+	tx, err := r.beginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	_, err = tx.ExecContext(ctx, `
+		DELETE FROM pull_request_reviewers
+		WHERE pull_request_id=? AND user_id NOT IN (SELECT value FROM json_each(?))
+	`, id, jsonIDs(reviewers))
+	if err != nil {
+		return err
+	}
+
+	now := sqliteTime(time.Now())
+	for _, rid := range reviewers {
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO pull_request_reviewers(pull_request_id, user_id, assigned_at)
+			 VALUES (?, ?, ?)
+			 ON CONFLICT (pull_request_id, user_id) DO NOTHING`, id, rid, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+/*
+SetReviewState сохраняет решение ревьювера по PR.
+Возвращает sql.ErrNoRows, если пользователь не назначен на этот PR.
+*/
+func (r *SQLiteRepo) SetReviewState(ctx context.Context, prID, userID string, state model.ReviewState, at time.Time) error {
+	res, err := r.conn(ctx).ExecContext(ctx, `
+		UPDATE pull_request_reviewers
+		SET state=?, submitted_at=?
+		WHERE pull_request_id=? AND user_id=?
+	`, state, sqliteTime(at), prID, userID)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+/*
+SetReviewerOrigin сохраняет, откуда взят ревьювер PR.
+Возвращает sql.ErrNoRows, если пользователь не назначен на этот PR.
+*/
+func (r *SQLiteRepo) SetReviewerOrigin(ctx context.Context, prID, userID string, o model.ReviewerOrigin) error {
+	res, err := r.conn(ctx).ExecContext(ctx, `
+		UPDATE pull_request_reviewers
+		SET fallback_team=?, owner_pattern=?, match_reason=?, matched_tags=?
+		WHERE pull_request_id=? AND user_id=?
+	`, o.FallbackTeam, o.OwnerPattern, o.MatchReason, strings.Join(o.MatchedTags, " "), prID, userID)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+/*
+GetReviewCandidates возвращает всех активных и доступных сейчас участников команды,
+кроме указанных пользователей, вместе с их текущей нагрузкой и лимитом, в порядке user_id.
+*/
+func (r *SQLiteRepo) GetReviewCandidates(
+	ctx context.Context, team string, exclude []string) ([]model.ReviewCandidate, error) {
+
+	now := sqliteTime(time.Now())
+	rows, err := r.conn(ctx).QueryContext(ctx, `
+		SELECT u.user_id, COALESCE(l.open_reviews, 0), u.max_open_reviews, u.tags, u.level
+		FROM users u
+		LEFT JOIN (`+sqliteOpenReviewsQuery+`) l ON l.user_id = u.user_id
+		WHERE u.team_name=? AND u.is_active=1
+			AND u.user_id NOT IN (SELECT value FROM json_each(?))
+			AND NOT EXISTS (
+				SELECT 1 FROM user_unavailability w
+				WHERE w.user_id = u.user_id AND w.starts_at <= ? AND ? < w.ends_at)
+		ORDER BY u.user_id
+	`, team, jsonIDs(exclude), now, now)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	result := []model.ReviewCandidate{}
+	for rows.Next() {
+		var c model.ReviewCandidate
+		var tags string
+		if err := rows.Scan(&c.UserID, &c.OpenReviews, &c.MaxOpenReviews, &tags, &c.Level); err != nil {
+			return nil, err
+		}
+		c.Tags = strings.Fields(tags)
+		result = append(result, c)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+/*
+GetPullRequestsByReviewer возвращает список PR,
+где пользователь является ревьювером.
+*/
+func (r *SQLiteRepo) GetPullRequestsByReviewer(ctx context.Context, uid string) ([]model.PullRequestShort, error) {
+	rows, err := r.conn(ctx).QueryContext(ctx, `
+		SELECT pr.pull_request_id, pr.pull_request_name, pr.author_id, pr.status
+		FROM pull_requests pr
+		JOIN pull_request_reviewers r ON r.pull_request_id = pr.pull_request_id
+		WHERE r.user_id=?
+	`, uid)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	result := []model.PullRequestShort{}
+	for rows.Next() {
+		var p model.PullRequestShort
+		if err := rows.Scan(&p.ID, &p.Name, &p.AuthorID, &p.Status); err != nil {
+			return nil, err
+		}
+		result = append(result, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+/*
+ListPullRequests возвращает до f.Limit PR, подходящих под фильтр f,
+в порядке f.Sort, начиная после курсора f.After.
+*/
+func (r *SQLiteRepo) ListPullRequests(ctx context.Context, f model.PullRequestFilter) ([]model.PullRequestShort, error) {
+	q, args := pullRequestListQuery(f,
+		func(int) string { return "?" },
+		func(t time.Time) interface{} { return sqliteTime(t) },
+	)
+	rows, err := r.conn(ctx).QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	result := []model.PullRequestShort{}
+	for rows.Next() {
+		var p model.PullRequestShort
+		var created string
+		var merged sql.NullString
+		if err := rows.Scan(&p.ID, &p.Name, &p.AuthorID, &p.Status, &created, &merged); err != nil {
+			return nil, err
+		}
+		createdAt, err := parseSQLiteTime(created)
+		if err != nil {
+			return nil, err
+		}
+		p.CreatedAt = &createdAt
+		if p.MergedAt, err = parseSQLiteNullTime(merged); err != nil {
+			return nil, err
+		}
+		result = append(result, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+/*
+GetUnderstaffedPullRequests возвращает id открытых PR авторов из команды team,
+у которых меньше want ревьюверов, от старых к новым.
+*/
+func (r *SQLiteRepo) GetUnderstaffedPullRequests(ctx context.Context, team string, want int) ([]string, error) {
+	rows, err := r.conn(ctx).QueryContext(ctx, `
+		SELECT pr.pull_request_id
+		FROM pull_requests pr
+		JOIN users a ON a.user_id = pr.author_id
+		LEFT JOIN pull_request_reviewers r ON r.pull_request_id = pr.pull_request_id
+		WHERE a.team_name=? AND pr.status = 'OPEN'
+		GROUP BY pr.pull_request_id, pr.created_at
+		HAVING COUNT(r.user_id) < ?
+		ORDER BY pr.created_at, pr.pull_request_id
+	`, team, want)
+	if err != nil {
+		return nil, err
+	}
+	return scanIDs(rows)
+}
+
+/*
+GetRecentReviewers возвращает ревьюверов последних n PR автора, у которых
+есть ревьюверы: по списку на PR, от новых к старым.
+*/
+func (r *SQLiteRepo) GetRecentReviewers(ctx context.Context, author string, n int) ([][]string, error) {
+	rows, err := r.conn(ctx).QueryContext(ctx, `
+		SELECT r.pull_request_id, r.user_id
+		FROM pull_request_reviewers r
+		JOIN (
+			SELECT pr.pull_request_id, pr.created_at
+			FROM pull_requests pr
+			WHERE pr.author_id=? AND EXISTS (
+				SELECT 1 FROM pull_request_reviewers x WHERE x.pull_request_id = pr.pull_request_id)
+			ORDER BY pr.created_at DESC, pr.pull_request_id DESC
+			LIMIT ?
+		) p ON p.pull_request_id = r.pull_request_id
+		ORDER BY p.created_at DESC, p.pull_request_id DESC, r.user_id
+	`, author, n)
+	if err != nil {
+		return nil, err
+	}
+	return scanRecentReviewers(rows)
+}
+
+// CreateAssignment сохраняет запись о выборе ревьюверов PR.
+func (r *SQLiteRepo) CreateAssignment(ctx context.Context, a model.Assignment) (*model.Assignment, error) {
+	excluded, steps, chosen, err := assignmentJSON(a)
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.conn(ctx).QueryRowContext(ctx, `
+		INSERT INTO pull_request_assignments(pull_request_id, kind, seed, strategy, excluded, steps, chosen, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING assignment_id
+	`, a.PullRequestID, a.Kind, a.Seed, a.Strategy, excluded, steps, chosen, sqliteTime(a.CreatedAt)).Scan(&a.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// ListAssignments возвращает записи о выборе ревьюверов PR в порядке создания.
+func (r *SQLiteRepo) ListAssignments(ctx context.Context, prID string) ([]model.Assignment, error) {
+	rows, err := r.conn(ctx).QueryContext(ctx, `
+		SELECT assignment_id, pull_request_id, kind, seed, strategy, excluded, steps, chosen, created_at
+		FROM pull_request_assignments
+		WHERE pull_request_id=?
+		ORDER BY assignment_id
+	`, prID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	result := []model.Assignment{}
+	for rows.Next() {
+		var a model.Assignment
+		var excluded, steps, chosen, created string
+		if err := rows.Scan(&a.ID, &a.PullRequestID, &a.Kind, &a.Seed, &a.Strategy,
+			&excluded, &steps, &chosen, &created); err != nil {
+			return nil, err
+		}
+		if err := decodeAssignment(&a, []byte(excluded), []byte(steps), []byte(chosen)); err != nil {
+			return nil, err
+		}
+		if a.CreatedAt, err = parseSQLiteTime(created); err != nil {
+			return nil, err
+		}
+		result = append(result, a)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// GetReviewerAssignmentStats возвращает количество назначений ревьюверов по каждому пользователю.
+func (r *SQLiteRepo) GetReviewerAssignmentStats(ctx context.Context) ([]model.ReviewerStat, error) {
+	rows, err := r.conn(ctx).QueryContext(ctx, `
+		SELECT r.user_id,
+			COUNT(*) AS assignments,
+			SUM(CASE WHEN pr.status = 'OPEN' THEN 1 ELSE 0 END) AS open_assignments
+		FROM pull_request_reviewers r
+		JOIN pull_requests pr ON pr.pull_request_id = r.pull_request_id
+		GROUP BY r.user_id
+		ORDER BY assignments DESC, r.user_id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var stats []model.ReviewerStat
+	for rows.Next() {
+		var s model.ReviewerStat
+		if err := rows.Scan(&s.UserID, &s.Assignments, &s.OpenAssignments); err != nil {
+			return nil, err
+		}
+		stats = append(stats, s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,105 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+)
+
+/*
+sqlConn — общая для SQL-репозиториев работа с соединением и транзакциями.
+Встраивается в PostgresRepo и SQLiteRepo.
+*/
+type sqlConn struct {
+	db *sql.DB
+}
+
+// txKey — ключ контекста, под которым InTx хранит открытую транзакцию.
+type txKey struct{}
+
+// ctxTx — транзакция в контексте вместе с репозиторием, который её открыл.
+type ctxTx struct {
+	owner *sqlConn
+	tx    *sql.Tx
+}
+
+// querier — общее подмножество методов *sql.DB и *sql.Tx.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// currentTx возвращает транзакцию этого репозитория, открытую через InTx.
+func (c *sqlConn) currentTx(ctx context.Context) (*sql.Tx, bool) {
+	t, ok := ctx.Value(txKey{}).(ctxTx)
+	if !ok || t.owner != c {
+		return nil, false
+	}
+	return t.tx, true
+}
+
+/*
+InTx выполняет fn в одной транзакции. Все методы репозитория, вызванные
+с контекстом, переданным в fn, работают внутри неё. Если fn возвращает
+ошибку, транзакция откатывается. Вложенный вызов переиспользует внешнюю транзакцию.
+*/
+func (c *sqlConn) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := c.currentTx(ctx); ok {
+		return fn(ctx)
+	}
+
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := fn(context.WithValue(ctx, txKey{}, ctxTx{owner: c, tx: tx})); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// conn возвращает транзакцию из контекста, если она открыта, иначе пул соединений.
+func (c *sqlConn) conn(ctx context.Context) querier {
+	if tx, ok := c.currentTx(ctx); ok {
+		return tx
+	}
+	return c.db
+}
+
+/*
+txScope — транзакция отдельного метода репозитория. Если метод вызван
+внутри InTx, он работает во внешней транзакции, а Commit и Rollback
+ничего не делают: результат фиксирует или откатывает InTx.
+*/
+type txScope struct {
+	*sql.Tx
+	nested bool
+}
+
+func (t *txScope) Commit() error {
+	if t.nested {
+		return nil
+	}
+	return t.Tx.Commit()
+}
+
+func (t *txScope) Rollback() error {
+	if t.nested {
+		return nil
+	}
+	return t.Tx.Rollback()
+}
+
+func (c *sqlConn) beginTx(ctx context.Context) (*txScope, error) {
+	if tx, ok := c.currentTx(ctx); ok {
+		return &txScope{Tx: tx, nested: true}, nil
+	}
+
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &txScope{Tx: tx}, nil
+}
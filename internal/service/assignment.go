@@ -0,0 +1,180 @@
+package service
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"pr-review-service/internal/model"
+)
+
+/*
+assignmentTrace накапливает сведения об одном автоматическом выборе
+ревьюверов, которые затем сохраняются как model.Assignment.
+*/
+type assignmentTrace struct {
+	seed     int64
+	excluded []string
+	steps    []model.AssignmentStep
+}
+
+// traceKey — ключ контекста с assignmentTrace текущего выбора.
+type traceKey struct{}
+
+/*
+startAssignment начинает выбор ревьюверов: берёт зерно следующего выбора,
+кладёт в контекст источник случайности от него (см. Rand) и запись шагов.
+excluded — пользователи, исключённые из выбора заранее.
+
+Предпросмотр (DryRun) зерно не расходует: следующий настоящий выбор
+получит то же зерно.
+*/
+func (s *Service) startAssignment(ctx context.Context, excluded []string) (context.Context, *assignmentTrace) {
+	s.mu.Lock()
+	seed := s.next
+	if !DryRun(ctx) {
+		s.next = s.seeds.Int63()
+	}
+	s.mu.Unlock()
+
+	tr := &assignmentTrace{seed: seed, excluded: dedupe(excluded)}
+	ctx = context.WithValue(ctx, traceKey{}, tr)
+	return withRand(ctx, rand.New(rand.NewSource(seed))), tr
+}
+
+/*
+strategy возвращает стратегию, которой выбирались ревьюверы: общую для всех
+шагов со стратегией (TEAM и FALLBACK_TEAM) или "", если таких шагов не было
+или резервные команды выбирали другой стратегией, чем основная.
+*/
+func (tr *assignmentTrace) strategy() string {
+	result := ""
+	for _, step := range tr.steps {
+		if step.Strategy == "" {
+			continue
+		}
+		if result != "" && step.Strategy != result {
+			return ""
+		}
+		result = step.Strategy
+	}
+	return result
+}
+
+// recordStep добавляет шаг к записи текущего выбора, если она ведётся.
+func recordStep(ctx context.Context, step model.AssignmentStep) {
+	if tr, ok := ctx.Value(traceKey{}).(*assignmentTrace); ok {
+		tr.steps = append(tr.steps, step)
+	}
+}
+
+/*
+candidateStep описывает шаг выбора среди candidates: кандидаты с запасом
+лимита попадают в пул, остальные — в at_capacity. Выбранных добавляет вызывающий.
+*/
+func candidateStep(reason model.MatchReason, team string, candidates []model.ReviewCandidate) model.AssignmentStep {
+	step := model.AssignmentStep{
+		Reason:     reason,
+		Team:       team,
+		Pool:       []string{},
+		AtCapacity: []string{},
+		Chosen:     []string{},
+	}
+	for _, c := range candidates {
+		if c.HasCapacity() {
+			step.Pool = append(step.Pool, c.UserID)
+		} else {
+			step.AtCapacity = append(step.AtCapacity, c.UserID)
+		}
+	}
+	return step
+}
+
+// saveAssignment сохраняет запись о выборе tr для PR; chosen — итог выбора.
+func (s *Service) saveAssignment(ctx context.Context, prID string, kind model.AssignmentKind, tr *assignmentTrace, chosen []string) error {
+	steps := tr.steps
+	if steps == nil {
+		steps = []model.AssignmentStep{}
+	}
+	_, err := s.repo.CreateAssignment(ctx, model.Assignment{
+		PullRequestID: prID,
+		Kind:          kind,
+		Seed:          tr.seed,
+		Strategy:      tr.strategy(),
+		Excluded:      tr.excluded,
+		Steps:         steps,
+		Chosen:        append([]string{}, chosen...),
+		CreatedAt:     time.Now().UTC(),
+	})
+	return err
+}
+
+/*
+GetAssignments объясняет автоматические назначения ревьюверов PR:
+для каждого выбора — зерно и стратегию, исключённых пользователей,
+пулы кандидатов по шагам и выбранных, в порядке выбора.
+
+Эндпоинт: GET /pullRequest/assignments?pull_request_id=...
+*/
+func (s *Service) GetAssignments(ctx context.Context, prID string) ([]model.Assignment, error) {
+	if _, err := s.getPR(ctx, prID); err != nil {
+		return nil, err
+	}
+	return s.repo.ListAssignments(ctx, prID)
+}
+
+/*
+PreviewReviewers выбирает ревьюверов для PR автора с изменёнными файлами paths
+и метками labels так же, как CreatePR, но ничего не сохраняет: возвращает
+предлагаемых ревьюверов, исключённых пользователей и пулы кандидатов по шагам.
+Предпросмотр берёт зерно следующего автоматического выбора, не расходуя его,
+но PR, созданный затем, может получить других ревьюверов, если до него
+прошёл другой выбор или изменились данные.
+
+Эндпоинт: GET /pullRequest/previewReviewers?author_id=...
+*/
+func (s *Service) PreviewReviewers(ctx context.Context, author string, paths, labels []string) (*model.ReviewerPreview, error) {
+	paths, err := normalizePaths(paths)
+	if err != nil {
+		return nil, err
+	}
+	labels, err = normalizeTags(labels)
+	if err != nil {
+		return nil, err
+	}
+
+	a, err := s.initialReviewers(withDryRun(ctx), author, paths, labels)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &model.ReviewerPreview{
+		AuthorID:     author,
+		Seed:         a.trace.seed,
+		Strategy:     a.trace.strategy(),
+		Excluded:     a.trace.excluded,
+		Steps:        a.trace.steps,
+		Reviewers:    []model.ProposedReviewer{},
+		Understaffed: len(a.reviewers) < a.want,
+	}
+	if p.Steps == nil {
+		p.Steps = []model.AssignmentStep{}
+	}
+	for _, uid := range a.reviewers {
+		p.Reviewers = append(p.Reviewers, model.ProposedReviewer{UserID: uid, ReviewerOrigin: a.origins[uid]})
+	}
+	return p, nil
+}
+
+// dedupe убирает повторы, сохраняя порядок; nil превращается в пустой список.
+func dedupe(ids []string) []string {
+	seen := map[string]bool{}
+	result := []string{}
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			result = append(result, id)
+		}
+	}
+	return result
+}
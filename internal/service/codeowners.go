@@ -0,0 +1,344 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"pr-review-service/internal/model"
+)
+
+/*
+CodeownersError — ошибка разбора или проверки файла CODEOWNERS
+с номером строки. Сводится к ErrInvalid через errors.Is.
+*/
+type CodeownersError struct {
+	Line   int
+	Reason string
+}
+
+func (e *CodeownersError) Error() string {
+	return fmt.Sprintf("CODEOWNERS line %d: %s", e.Line, e.Reason)
+}
+
+func (e *CodeownersError) Unwrap() error { return ErrInvalid }
+
+/*
+GetCodeowners возвращает правила CODEOWNERS команды.
+
+Эндпоинт: GET /team/codeowners?team_name=...
+*/
+func (s *Service) GetCodeowners(ctx context.Context, team string) ([]model.OwnershipRule, error) {
+	if _, err := s.GetTeamSettings(ctx, team); err != nil {
+		return nil, err
+	}
+	return s.repo.GetOwnershipRules(ctx, team)
+}
+
+/*
+SetCodeowners заменяет правила владения кодом команды текстом в синтаксисе
+GitHub CODEOWNERS. Владельцы — @user_id или @org/team (организация
+не учитывается), все они должны существовать. Ошибки возвращаются
+как *CodeownersError.
+
+Эндпоинт: POST /team/codeowners
+*/
+func (s *Service) SetCodeowners(ctx context.Context, team, text string) ([]model.OwnershipRule, error) {
+	rules, lines, err := parseCodeowners(text)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, rule := range rules {
+		for _, tok := range rule.Owners {
+			if err := s.checkCodeowner(ctx, tok); err != nil {
+				var ce *CodeownersError
+				if errors.As(err, &ce) {
+					ce.Line = lines[i]
+				}
+				return nil, err
+			}
+		}
+	}
+
+	if err := s.repo.SetOwnershipRules(ctx, team, rules); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return rules, nil
+}
+
+// checkCodeowner проверяет, что владелец из правила существует.
+func (s *Service) checkCodeowner(ctx context.Context, tok string) error {
+	owner, _ := parseCodeowner(tok)
+
+	var err error
+	if owner.team != "" {
+		_, err = s.repo.GetTeamSettings(ctx, owner.team)
+	} else {
+		_, err = s.repo.GetUserByID(ctx, owner.user)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return &CodeownersError{Reason: "unknown owner " + tok}
+	}
+	return err
+}
+
+/*
+parseCodeowners разбирает текст CODEOWNERS: строка — шаблон пути и владельцы
+через пробел, # начинает комментарий. Правило без владельцев допустимо
+и снимает владельцев с подпадающих под него файлов. Вторым значением
+возвращаются номера строк правил.
+*/
+func parseCodeowners(text string) ([]model.OwnershipRule, []int, error) {
+	rules := []model.OwnershipRule{}
+	lines := []int{}
+	for i, line := range strings.Split(text, "\n") {
+		if idx := strings.Index(line, "#"); idx >= 0 {
+			line = line[:idx]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		pattern := fields[0]
+		if strings.HasPrefix(pattern, "!") || strings.ContainsAny(pattern, "[]") {
+			return nil, nil, &CodeownersError{Line: i + 1, Reason: "unsupported pattern " + pattern}
+		}
+		for _, tok := range fields[1:] {
+			if _, ok := parseCodeowner(tok); !ok {
+				return nil, nil, &CodeownersError{Line: i + 1, Reason: "invalid owner " + tok}
+			}
+		}
+
+		rules = append(rules, model.OwnershipRule{Pattern: pattern, Owners: append([]string{}, fields[1:]...)})
+		lines = append(lines, i+1)
+	}
+	return rules, lines, nil
+}
+
+// codeowner — владелец из правила CODEOWNERS: пользователь или команда.
+type codeowner struct {
+	user string
+	team string
+}
+
+// parseCodeowner разбирает владельца вида @user_id или @org/team.
+func parseCodeowner(tok string) (codeowner, bool) {
+	name, ok := strings.CutPrefix(tok, "@")
+	if !ok || name == "" {
+		return codeowner{}, false
+	}
+
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		if i == 0 || i == len(name)-1 {
+			return codeowner{}, false
+		}
+		return codeowner{team: name[i+1:]}, true
+	}
+	return codeowner{user: name}, true
+}
+
+/*
+matchOwnershipRule возвращает правило для пути: как в CODEOWNERS,
+действует последнее подходящее правило. nil — путь никому не принадлежит.
+*/
+func matchOwnershipRule(rules []model.OwnershipRule, path string) *model.OwnershipRule {
+	path = strings.TrimPrefix(path, "/")
+	for i := len(rules) - 1; i >= 0; i-- {
+		if codeownersPattern(rules[i].Pattern).MatchString(path) {
+			return &rules[i]
+		}
+	}
+	return nil
+}
+
+/*
+codeownersPattern переводит шаблон CODEOWNERS (семантика .gitignore)
+в регулярное выражение:
+
+  - шаблон без "/" (кроме завершающего) совпадает на любой глубине,
+    иначе — от корня репозитория;
+  - "*" и "?" не переходят через "/", "**" — переходит;
+  - шаблон совпадает и с каталогом, и со всем его содержимым,
+    а завершающий "/" — только с содержимым каталога.
+*/
+func codeownersPattern(pattern string) *regexp.Regexp {
+	dirOnly := strings.HasSuffix(pattern, "/")
+	p := strings.TrimSuffix(pattern, "/")
+	anchored := strings.Contains(p, "/")
+	p = strings.TrimPrefix(p, "/")
+
+	var b strings.Builder
+	if anchored {
+		b.WriteString("^")
+	} else {
+		b.WriteString("^(?:.*/)?")
+	}
+
+	for i := 0; i < len(p); i++ {
+		switch {
+		case strings.HasPrefix(p[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(p[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case p[i] == '*':
+			b.WriteString("[^/]*")
+		case p[i] == '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(p[i : i+1]))
+		}
+	}
+
+	if dirOnly {
+		b.WriteString("/.*$")
+	} else {
+		b.WriteString("(?:/.*)?$")
+	}
+	return regexp.MustCompile(b.String())
+}
+
+/*
+selectOwners выбирает владельцев кода для изменённых файлов PR по правилам
+CODEOWNERS команды автора: для каждого файла, подпадающего под правило,
+хотя бы одного его владельца. Уже выбранный владелец покрывает все свои
+файлы; из свободных владельцев берётся наименее загруженный. Файлы,
+ни один владелец которых сейчас не может взять ревью, пропускаются.
+Для каждого сработавшего правила записывается шаг выбора CODEOWNER.
+*/
+func (s *Service) selectOwners(ctx context.Context, team, author string, paths []string) ([]string, map[string]model.ReviewerOrigin, error) {
+	chosen := []string{}
+	origins := map[string]model.ReviewerOrigin{}
+	if len(paths) == 0 {
+		return chosen, origins, nil
+	}
+
+	rules, err := s.repo.GetOwnershipRules(ctx, team)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	steps := []model.AssignmentStep{}
+	stepOf := map[*model.OwnershipRule]int{}
+	for _, path := range paths {
+		rule := matchOwnershipRule(rules, path)
+		if rule == nil {
+			continue
+		}
+
+		pool, err := s.ownerCandidates(ctx, rule.Owners, author)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		i, ok := stepOf[rule]
+		if !ok {
+			i = len(steps)
+			stepOf[rule] = i
+			steps = append(steps, candidateStep(model.MatchCodeowner, team, pool))
+			steps[i].OwnerPattern = rule.Pattern
+		}
+
+		if owner := pickOwner(pool, chosen); owner != "" {
+			chosen = append(chosen, owner)
+			origins[owner] = model.ReviewerOrigin{MatchReason: model.MatchCodeowner, OwnerPattern: rule.Pattern}
+			steps[i].Chosen = append(steps[i].Chosen, owner)
+		}
+	}
+
+	for _, step := range steps {
+		recordStep(ctx, step)
+	}
+	return chosen, origins, nil
+}
+
+/*
+ownerCandidates раскрывает владельцев правила в активных и доступных
+пользователей: команда — во всех её кандидатов. Автор PR исключается.
+*/
+func (s *Service) ownerCandidates(ctx context.Context, owners []string, author string) ([]model.ReviewCandidate, error) {
+	byTeam := map[string][]model.ReviewCandidate{}
+	teamCandidates := func(team string) ([]model.ReviewCandidate, error) {
+		if c, ok := byTeam[team]; ok {
+			return c, nil
+		}
+		c, err := s.repo.GetReviewCandidates(ctx, team, []string{author})
+		if err != nil {
+			return nil, err
+		}
+		byTeam[team] = c
+		return c, nil
+	}
+
+	seen := map[string]bool{}
+	result := []model.ReviewCandidate{}
+	for _, tok := range owners {
+		owner, _ := parseCodeowner(tok)
+
+		team, user := owner.team, owner.user
+		if user != "" {
+			u, err := s.repo.GetUserByID(ctx, user)
+			if errors.Is(err, sql.ErrNoRows) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			team = u.TeamName
+		}
+
+		candidates, err := teamCandidates(team)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range candidates {
+			if (user == "" || c.UserID == user) && !seen[c.UserID] {
+				seen[c.UserID] = true
+				result = append(result, c)
+			}
+		}
+	}
+	return result, nil
+}
+
+/*
+pickOwner возвращает владельца, которого нужно добавить к chosen,
+или "", если файл уже покрыт кем-то из chosen либо свободных владельцев нет.
+*/
+func pickOwner(pool []model.ReviewCandidate, chosen []string) string {
+	picked := map[string]bool{}
+	for _, uid := range chosen {
+		picked[uid] = true
+	}
+
+	free := []model.ReviewCandidate{}
+	for _, c := range pool {
+		if picked[c.UserID] {
+			return ""
+		}
+		if c.HasCapacity() {
+			free = append(free, c)
+		}
+	}
+	if len(free) == 0 {
+		return ""
+	}
+
+	sort.Slice(free, func(i, j int) bool {
+		if free[i].OpenReviews != free[j].OpenReviews {
+			return free[i].OpenReviews < free[j].OpenReviews
+		}
+		return free[i].UserID < free[j].UserID
+	})
+	return free[0].UserID
+}
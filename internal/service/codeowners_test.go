@@ -0,0 +1,130 @@
+package service
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"pr-review-service/internal/model"
+)
+
+func TestCodeownersPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		match   []string
+		noMatch []string
+	}{
+		{
+			pattern: "*.go",
+			match:   []string{"main.go", "cmd/app/main.go", "a/b/c/d.go"},
+			noMatch: []string{"main.go.bak", "go", "main.gox"},
+		},
+		{
+			pattern: "/docs/",
+			match:   []string{"docs/README.md", "docs/api/v1.md"},
+			noMatch: []string{"docs", "src/docs/README.md", "docsx/a.md"},
+		},
+		{
+			pattern: "a/**/b",
+			match:   []string{"a/b", "a/x/b", "a/x/y/b", "a/x/b/c.go"},
+			noMatch: []string{"x/a/b", "a/bc", "a/x/bc"},
+		},
+		{
+			pattern: "foo/**",
+			match:   []string{"foo/a.go", "foo/x/y/z.go"},
+			noMatch: []string{"foo", "bar/foo/a.go", "foobar/a.go"},
+		},
+		{
+			pattern: "api/",
+			match:   []string{"api/users.go", "internal/api/users.go"},
+			noMatch: []string{"api", "apis/users.go"},
+		},
+		{
+			pattern: "cmd/app",
+			match:   []string{"cmd/app", "cmd/app/main.go"},
+			noMatch: []string{"x/cmd/app", "cmd/application"},
+		},
+		{
+			pattern: "api/*.go",
+			match:   []string{"api/users.go"},
+			noMatch: []string{"api/v1/users.go", "x/api/users.go"},
+		},
+		{
+			pattern: "?.md",
+			match:   []string{"a.md", "docs/b.md"},
+			noMatch: []string{"ab.md", ".md"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.pattern, func(t *testing.T) {
+			re := codeownersPattern(tc.pattern)
+			for _, path := range tc.match {
+				if !re.MatchString(path) {
+					t.Errorf("%q does not match %q (%s)", tc.pattern, path, re)
+				}
+			}
+			for _, path := range tc.noMatch {
+				if re.MatchString(path) {
+					t.Errorf("%q matches %q (%s)", tc.pattern, path, re)
+				}
+			}
+		})
+	}
+}
+
+func TestParseCodeowners(t *testing.T) {
+	text := "# владельцы\n" +
+		"\n" +
+		"*.go @u1 @org/backend # код\n" +
+		"/docs/ @u2\n" +
+		"vendor/\n"
+
+	rules, lines, err := parseCodeowners(text)
+	if err != nil {
+		t.Fatalf("parseCodeowners: %v", err)
+	}
+	wantRules := []model.OwnershipRule{
+		{Pattern: "*.go", Owners: []string{"@u1", "@org/backend"}},
+		{Pattern: "/docs/", Owners: []string{"@u2"}},
+		{Pattern: "vendor/", Owners: []string{}},
+	}
+	if !reflect.DeepEqual(rules, wantRules) {
+		t.Fatalf("rules: got %+v, want %+v", rules, wantRules)
+	}
+	if want := []int{3, 4, 5}; !reflect.DeepEqual(lines, want) {
+		t.Fatalf("lines: got %v, want %v", lines, want)
+	}
+}
+
+func TestParseCodeownersErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		line int
+	}{
+		{"negation", "!*.go @u1", 1},
+		{"character class", "*.[ch] @u1", 1},
+		{"closing bracket", "# ok\nfoo]/ @u1", 2},
+		{"owner without @", "*.go u1", 1},
+		{"bare @", "*.go @", 1},
+		{"empty team", "*.go @org/", 1},
+		{"empty org", "*.go @u1\n*.md @/docs", 2},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, _, err := parseCodeowners(tc.text)
+			var ce *CodeownersError
+			if !errors.As(err, &ce) {
+				t.Fatalf("got %v, want *CodeownersError", err)
+			}
+			if ce.Line != tc.line {
+				t.Fatalf("line: got %d, want %d", ce.Line, tc.line)
+			}
+			if !errors.Is(err, ErrInvalid) {
+				t.Fatalf("%v is not ErrInvalid", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"pr-review-service/internal/model"
+)
+
+/*
+prTransition — переход жизненного цикла PR: из каких статусов он допустим
+и в какой статус переводит. Жизненный цикл:
+
+	DRAFT ──ready──▶ OPEN ──merge──▶ MERGED
+	DRAFT, OPEN ──close──▶ CLOSED ──reopen──▶ OPEN
+*/
+type prTransition struct {
+	from []model.PullRequestStatus
+	to   model.PullRequestStatus
+}
+
+var (
+	transitionReady  = prTransition{from: []model.PullRequestStatus{model.PRStatusDraft}, to: model.PRStatusOpen}
+	transitionMerge  = prTransition{from: []model.PullRequestStatus{model.PRStatusOpen}, to: model.PRStatusMerged}
+	transitionClose  = prTransition{from: []model.PullRequestStatus{model.PRStatusDraft, model.PRStatusOpen}, to: model.PRStatusClosed}
+	transitionReopen = prTransition{from: []model.PullRequestStatus{model.PRStatusClosed}, to: model.PRStatusOpen}
+)
+
+/*
+check проверяет, можно ли выполнить переход для PR. done = true, если PR
+уже в целевом статусе: повторный переход идемпотентен и ничего не меняет.
+Недопустимый переход отклоняется ошибкой текущего статуса (statusError).
+*/
+func (t prTransition) check(pr *model.PullRequest) (done bool, err error) {
+	if pr.Status == t.to {
+		return true, nil
+	}
+	for _, st := range t.from {
+		if pr.Status == st {
+			return false, nil
+		}
+	}
+	return false, statusError(pr.Status)
+}
+
+// statusError возвращает ошибку, которой отклоняются операции над PR в статусе st.
+func statusError(st model.PullRequestStatus) error {
+	switch st {
+	case model.PRStatusMerged:
+		return ErrPRMerged
+	case model.PRStatusClosed:
+		return ErrPRClosed
+	case model.PRStatusDraft:
+		return ErrPRDraft
+	}
+	return ErrInvalid
+}
+
+// requireOpen разрешает работу с ревьюверами только для PR в статусе OPEN.
+func requireOpen(pr *model.PullRequest) error {
+	if pr.Status != model.PRStatusOpen {
+		return statusError(pr.Status)
+	}
+	return nil
+}
+
+/*
+MarkReady переводит черновик в OPEN и назначает ревьюверов
+так же, как при создании PR.
+
+Эндпоинт: POST /pullRequest/markReady.
+*/
+func (s *Service) MarkReady(ctx context.Context, id string) (*model.PullRequest, error) {
+	return s.openPR(ctx, id, transitionReady, model.AssignmentReady)
+}
+
+/*
+ReopenPR переоткрывает закрытый PR и заново назначает ревьюверов.
+
+Эндпоинт: POST /pullRequest/reopen.
+*/
+func (s *Service) ReopenPR(ctx context.Context, id string) (*model.PullRequest, error) {
+	return s.openPR(ctx, id, transitionReopen, model.AssignmentReopen)
+}
+
+/*
+ClosePR закрывает PR без мерджа и освобождает его ревьюверов.
+
+Эндпоинт: POST /pullRequest/close.
+*/
+func (s *Service) ClosePR(ctx context.Context, id string) (*model.PullRequest, error) {
+	var pr *model.PullRequest
+	err := s.repo.InTx(ctx, func(ctx context.Context) error {
+		cur, err := s.lockPR(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		done, err := transitionClose.check(cur)
+		if err != nil || done {
+			pr = cur
+			return err
+		}
+
+		if err := s.repo.SetPRReviewers(ctx, id, nil); err != nil {
+			return err
+		}
+		if err := s.repo.SetPRStatus(ctx, id, model.PRStatusClosed, time.Now().UTC()); err != nil {
+			return err
+		}
+
+		pr, err = s.repo.GetPullRequestWithReviewers(ctx, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pr, nil
+}
+
+/*
+openPR выполняет переход t в OPEN и назначает PR новых ревьюверов;
+выбор сохраняется как назначение вида kind.
+*/
+func (s *Service) openPR(ctx context.Context, id string, t prTransition, kind model.AssignmentKind) (*model.PullRequest, error) {
+	var pr *model.PullRequest
+	err := s.repo.InTx(ctx, func(ctx context.Context) error {
+		cur, err := s.lockPR(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		done, err := t.check(cur)
+		if err != nil || done {
+			pr = cur
+			return err
+		}
+
+		a, err := s.initialReviewers(ctx, cur.AuthorID, cur.ChangedPaths, cur.Labels)
+		if err != nil {
+			return err
+		}
+		if err := s.repo.SetPRReviewers(ctx, id, a.reviewers); err != nil {
+			return err
+		}
+		if err := s.saveOrigins(ctx, id, a.origins); err != nil {
+			return err
+		}
+		if err := s.saveAssignment(ctx, id, kind, a.trace, a.reviewers); err != nil {
+			return err
+		}
+		if err := s.repo.SetPRStatus(ctx, id, model.PRStatusOpen, time.Now().UTC()); err != nil {
+			return err
+		}
+
+		pr, err = s.repo.GetPullRequestWithReviewers(ctx, id)
+		if err != nil {
+			return err
+		}
+		pr.Understaffed = len(pr.AssignedReviewers) < a.want
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pr, nil
+}
+
+/*
+lockPR блокирует PR до конца транзакции и загружает его. Вызывается
+первым в InTx, который проверяет PR и переписывает его ревьюверов.
+*/
+func (s *Service) lockPR(ctx context.Context, id string) (*model.PullRequest, error) {
+	if err := s.repo.LockPullRequest(ctx, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return s.getPR(ctx, id)
+}
+
+// getPR возвращает PR, превращая sql.ErrNoRows в ErrNotFound.
+func (s *Service) getPR(ctx context.Context, id string) (*model.PullRequest, error) {
+	pr, err := s.repo.GetPullRequestWithReviewers(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return pr, nil
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"pr-review-service/internal/model"
+)
+
+const (
+	// defaultListLimit — размер страницы списка PR, если limit не задан.
+	defaultListLimit = 20
+
+	// maxListLimit — наибольший допустимый размер страницы.
+	maxListLimit = 100
+)
+
+/*
+listCursor — содержимое курсора списка PR. Порядок входит в курсор,
+чтобы курсор нельзя было применить к списку с другой сортировкой.
+*/
+type listCursor struct {
+	Sort model.PullRequestSort `json:"s"`
+	At   time.Time             `json:"t"`
+	ID   string                `json:"id"`
+}
+
+/*
+ListPullRequests возвращает страницу PR, подходящих под фильтр f.
+Порядок по умолчанию — сначала новые; f.Limit — размер страницы
+(0 — defaultListLimit, не больше maxListLimit). cursor — next_cursor
+предыдущей страницы с тем же порядком, пустой — первая страница.
+
+Эндпоинт: GET /pullRequest/list
+*/
+func (s *Service) ListPullRequests(ctx context.Context, f model.PullRequestFilter, cursor string) (*model.PullRequestPage, error) {
+	if f.Sort == "" {
+		f.Sort = model.DefaultPullRequestSort
+	}
+	if !f.Sort.Valid() {
+		return nil, ErrInvalid
+	}
+	for _, st := range f.Statuses {
+		if !st.Valid() {
+			return nil, ErrInvalid
+		}
+	}
+	if f.Limit == 0 {
+		f.Limit = defaultListLimit
+	}
+	if f.Limit < 0 || f.Limit > maxListLimit {
+		return nil, ErrInvalid
+	}
+	if !validRange(f.CreatedFrom, f.CreatedTo) || !validRange(f.MergedFrom, f.MergedTo) {
+		return nil, ErrInvalid
+	}
+	if cursor != "" {
+		after, err := decodeCursor(cursor, f.Sort)
+		if err != nil {
+			return nil, err
+		}
+		f.After = after
+	}
+
+	// Лишний PR показывает, есть ли следующая страница.
+	limit := f.Limit
+	f.Limit++
+	list, err := s.repo.ListPullRequests(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &model.PullRequestPage{PullRequests: list}
+	if len(list) > limit {
+		page.PullRequests = list[:limit]
+		if page.NextCursor, err = encodeCursor(f.Sort, list[limit-1]); err != nil {
+			return nil, err
+		}
+	}
+	return page, nil
+}
+
+// validRange сообщает, что интервал [from, to) не пуст; nil-граница не ограничивает.
+func validRange(from, to *time.Time) bool {
+	return from == nil || to == nil || from.Before(*to)
+}
+
+/*
+encodeCursor строит курсор, указывающий на PR p в списке с порядком sort.
+Если у p нет времени, по которому отсортирован список, возвращается ошибка.
+*/
+func encodeCursor(sort model.PullRequestSort, p model.PullRequestShort) (string, error) {
+	at := p.CreatedAt
+	if sort.ByMerged() {
+		at = p.MergedAt
+	}
+	if at == nil {
+		return "", fmt.Errorf("pull request %q has no time to sort by %s", p.ID, sort)
+	}
+
+	b, err := json.Marshal(listCursor{Sort: sort, At: *at, ID: p.ID})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// decodeCursor разбирает курсор; курсор от списка с другим порядком недопустим.
+func decodeCursor(cursor string, sort model.PullRequestSort) (*model.PullRequestCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalid
+	}
+	var c listCursor
+	if err := json.Unmarshal(b, &c); err != nil || c.Sort != sort || c.ID == "" {
+		return nil, ErrInvalid
+	}
+	return &model.PullRequestCursor{At: c.At, ID: c.ID}, nil
+}
@@ -0,0 +1,150 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"pr-review-service/internal/model"
+	"pr-review-service/internal/repo/memory"
+	"pr-review-service/internal/service"
+)
+
+// newListService создаёт сервис над репозиторием в памяти с n черновиками автора u1.
+func newListService(t *testing.T, n int) *service.Service {
+	t.Helper()
+	ctx := context.Background()
+	s := service.NewService(memory.NewRepo(), service.Config{})
+
+	team := model.Team{TeamName: "backend", Members: []model.TeamMember{
+		{UserID: "u1", Username: "alice", IsActive: true},
+	}}
+	if _, _, err := s.CreateTeam(ctx, team); err != nil {
+		t.Fatalf("CreateTeam: %v", err)
+	}
+	for i := 1; i <= n; i++ {
+		req := model.NewPullRequest{ID: fmt.Sprintf("pr-%d", i), Name: "pr", AuthorID: "u1", Draft: true}
+		if _, err := s.CreatePR(ctx, req); err != nil {
+			t.Fatalf("CreatePR %s: %v", req.ID, err)
+		}
+	}
+	return s
+}
+
+func pageIDs(page *model.PullRequestPage) []string {
+	ids := []string{}
+	for _, pr := range page.PullRequests {
+		ids = append(ids, pr.ID)
+	}
+	return ids
+}
+
+func TestListPullRequestsCursorRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	s := newListService(t, 5)
+
+	for _, sort := range []model.PullRequestSort{"", model.SortCreatedAsc, model.SortCreatedDesc} {
+		t.Run(string(sort), func(t *testing.T) {
+			all, err := s.ListPullRequests(ctx, model.PullRequestFilter{Sort: sort}, "")
+			if err != nil {
+				t.Fatalf("ListPullRequests: %v", err)
+			}
+			want := pageIDs(all)
+			if len(want) != 5 || all.NextCursor != "" {
+				t.Fatalf("full list: got %v with cursor %q", want, all.NextCursor)
+			}
+
+			got := []string{}
+			cursor := ""
+			for pages := 0; ; pages++ {
+				if pages > 5 {
+					t.Fatalf("paging does not stop, got %v", got)
+				}
+				page, err := s.ListPullRequests(ctx, model.PullRequestFilter{Sort: sort, Limit: 2}, cursor)
+				if err != nil {
+					t.Fatalf("page %d: %v", pages, err)
+				}
+				got = append(got, pageIDs(page)...)
+				if page.NextCursor == "" {
+					break
+				}
+				cursor = page.NextCursor
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("pages: got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestListPullRequestsCursorSortMismatch(t *testing.T) {
+	ctx := context.Background()
+	s := newListService(t, 3)
+
+	page, err := s.ListPullRequests(ctx, model.PullRequestFilter{Sort: model.SortCreatedAsc, Limit: 1}, "")
+	if err != nil {
+		t.Fatalf("ListPullRequests: %v", err)
+	}
+	if page.NextCursor == "" {
+		t.Fatal("no next_cursor on the first page")
+	}
+
+	_, err = s.ListPullRequests(ctx, model.PullRequestFilter{Limit: 1}, page.NextCursor)
+	if !errors.Is(err, service.ErrInvalid) {
+		t.Fatalf("created_at cursor with the default sort: got %v, want ErrInvalid", err)
+	}
+	_, err = s.ListPullRequests(ctx, model.PullRequestFilter{Limit: 1}, "not a cursor")
+	if !errors.Is(err, service.ErrInvalid) {
+		t.Fatalf("malformed cursor: got %v, want ErrInvalid", err)
+	}
+}
+
+func TestListPullRequestsLimit(t *testing.T) {
+	ctx := context.Background()
+	s := newListService(t, 5)
+
+	tests := []struct {
+		limit      int
+		wantLen    int
+		wantCursor bool
+	}{
+		{limit: 4, wantLen: 4, wantCursor: true},
+		{limit: 5, wantLen: 5, wantCursor: false},
+		{limit: 6, wantLen: 5, wantCursor: false},
+	}
+	for _, tc := range tests {
+		t.Run(fmt.Sprint(tc.limit), func(t *testing.T) {
+			page, err := s.ListPullRequests(ctx, model.PullRequestFilter{Limit: tc.limit}, "")
+			if err != nil {
+				t.Fatalf("ListPullRequests: %v", err)
+			}
+			if len(page.PullRequests) != tc.wantLen {
+				t.Fatalf("got %d PRs, want %d", len(page.PullRequests), tc.wantLen)
+			}
+			if (page.NextCursor != "") != tc.wantCursor {
+				t.Fatalf("next_cursor %q, want present=%v", page.NextCursor, tc.wantCursor)
+			}
+		})
+	}
+
+	// Последняя неполная страница после курсора — без next_cursor.
+	first, err := s.ListPullRequests(ctx, model.PullRequestFilter{Limit: 4}, "")
+	if err != nil {
+		t.Fatalf("ListPullRequests: %v", err)
+	}
+	last, err := s.ListPullRequests(ctx, model.PullRequestFilter{Limit: 4}, first.NextCursor)
+	if err != nil {
+		t.Fatalf("ListPullRequests after cursor: %v", err)
+	}
+	if len(last.PullRequests) != 1 || last.NextCursor != "" {
+		t.Fatalf("last page: got %v with cursor %q", pageIDs(last), last.NextCursor)
+	}
+
+	for _, limit := range []int{-1, 101} {
+		if _, err := s.ListPullRequests(ctx, model.PullRequestFilter{Limit: limit}, ""); !errors.Is(err, service.ErrInvalid) {
+			t.Fatalf("limit %d: got %v, want ErrInvalid", limit, err)
+		}
+	}
+}
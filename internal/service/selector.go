@@ -0,0 +1,362 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"pr-review-service/internal/model"
+)
+
+// Strategy — имя стратегии выбора ревьюверов.
+type Strategy string
+
+const (
+	// StrategyRandom выбирает ревьюверов случайно (поведение по умолчанию).
+	StrategyRandom Strategy = "random"
+
+	// StrategyRoundRobin назначает участников команды по очереди.
+	StrategyRoundRobin Strategy = "round_robin"
+
+	// StrategyLeastLoaded выбирает участников с наименьшим числом открытых ревью.
+	StrategyLeastLoaded Strategy = "least_loaded"
+
+	// StrategyWeighted выбирает случайно с учётом весов пользователей.
+	StrategyWeighted Strategy = "weighted"
+
+	// StrategyRotation выбирает случайно, реже назначая недавних ревьюверов автора.
+	StrategyRotation Strategy = "rotation"
+)
+
+// ParseStrategy проверяет имя встроенной стратегии.
+func ParseStrategy(s string) (Strategy, error) {
+	switch st := Strategy(s); st {
+	case StrategyRandom, StrategyRoundRobin, StrategyLeastLoaded, StrategyWeighted, StrategyRotation:
+		return st, nil
+	}
+	return "", fmt.Errorf("unknown reviewer strategy %q", s)
+}
+
+/*
+ReviewerSelector выбирает до limit ревьюверов команды team среди candidates —
+активных и доступных участников без исключённых пользователей. Кандидатов
+сервис получает один раз и записывает их же в шаг выбора. Реализация
+не должна назначать кандидатов, исчерпавших лимит (HasCapacity).
+*/
+type ReviewerSelector interface {
+	SelectReviewers(ctx context.Context, team string, candidates []model.ReviewCandidate, limit int) ([]string, error)
+}
+
+// authorKey — ключ контекста с автором PR, для которого выбираются ревьюверы.
+type authorKey struct{}
+
+// WithPRAuthor добавляет в контекст автора PR, для которого выбираются ревьюверы.
+func WithPRAuthor(ctx context.Context, author string) context.Context {
+	return context.WithValue(ctx, authorKey{}, author)
+}
+
+// PRAuthor возвращает автора PR из контекста стратегии или "", если он неизвестен.
+func PRAuthor(ctx context.Context) string {
+	author, _ := ctx.Value(authorKey{}).(string)
+	return author
+}
+
+// randKey — ключ контекста с источником случайности текущего выбора ревьюверов.
+type randKey struct{}
+
+func withRand(ctx context.Context, rnd *rand.Rand) context.Context {
+	return context.WithValue(ctx, randKey{}, rnd)
+}
+
+/*
+Rand возвращает источник случайности текущего выбора ревьюверов. Он создаётся
+из зерна, сохранённого в записи о выборе (model.Assignment), поэтому стратегия,
+которая берёт случайность только отсюда, воспроизводима. Вне выбора
+возвращается источник, засеянный текущим временем.
+*/
+func Rand(ctx context.Context) *rand.Rand {
+	if rnd, ok := ctx.Value(randKey{}).(*rand.Rand); ok {
+		return rnd
+	}
+	return rand.New(rand.NewSource(time.Now().UnixNano()))
+}
+
+// dryRunKey — ключ контекста, помечающий предпросмотр выбора ревьюверов.
+type dryRunKey struct{}
+
+func withDryRun(ctx context.Context) context.Context {
+	return context.WithValue(ctx, dryRunKey{}, true)
+}
+
+/*
+DryRun сообщает, что выбор выполняется для предпросмотра и ничего не назначит.
+Стратегия с собственным состоянием не должна менять его в этом случае.
+*/
+func DryRun(ctx context.Context) bool {
+	dry, _ := ctx.Value(dryRunKey{}).(bool)
+	return dry
+}
+
+/*
+Config задаёт политику выбора ревьюверов: стратегию по умолчанию,
+переопределения для отдельных команд, веса пользователей
+для стратегии weighted и источник зёрен случайности.
+*/
+type Config struct {
+	DefaultStrategy Strategy
+	TeamStrategies  map[string]Strategy
+	Weights         map[string]int
+
+	// Rand выдаёт зёрна для каждого выбора ревьюверов; nil — засеять
+	// текущим временем. Фиксированный источник делает выбор воспроизводимым.
+	Rand rand.Source
+}
+
+// randomSelector выбирает кандидатов с запасом лимита равновероятно.
+type randomSelector struct{}
+
+func (s *randomSelector) SelectReviewers(ctx context.Context, team string, candidates []model.ReviewCandidate, limit int) ([]string, error) {
+	pool := withCapacity(candidates)
+	Rand(ctx).Shuffle(len(pool), func(i, j int) { pool[i], pool[j] = pool[j], pool[i] })
+	return pool[:max(0, min(limit, len(pool)))], nil
+}
+
+/*
+roundRobinSelector перебирает участников команды по кругу в порядке user_id.
+Позиция хранится в памяти процесса отдельно для каждой команды
+и не сдвигается при предпросмотре (DryRun).
+*/
+type roundRobinSelector struct {
+	mu   sync.Mutex
+	last map[string]string
+}
+
+func (s *roundRobinSelector) SelectReviewers(ctx context.Context, team string, candidates []model.ReviewCandidate, limit int) ([]string, error) {
+	pool := withCapacity(candidates)
+	if len(pool) == 0 || limit <= 0 {
+		return []string{}, nil
+	}
+	sort.Strings(pool)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	last := s.last[team]
+	start := sort.Search(len(pool), func(i int) bool { return pool[i] > last })
+
+	n := min(limit, len(pool))
+	result := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		result = append(result, pool[(start+i)%len(pool)])
+	}
+
+	if !DryRun(ctx) {
+		s.last[team] = result[n-1]
+	}
+	return result, nil
+}
+
+/*
+leastLoadedSelector выбирает участников с наименьшим числом ревью
+в открытых PR. При равной нагрузке порядок выбирается случайно.
+*/
+type leastLoadedSelector struct{}
+
+func (s *leastLoadedSelector) SelectReviewers(ctx context.Context, team string, candidates []model.ReviewCandidate, limit int) ([]string, error) {
+	pool := withCapacity(candidates)
+	load := map[string]int{}
+	for _, c := range candidates {
+		load[c.UserID] = c.OpenReviews
+	}
+	Rand(ctx).Shuffle(len(pool), func(i, j int) { pool[i], pool[j] = pool[j], pool[i] })
+	sort.SliceStable(pool, func(i, j int) bool { return load[pool[i]] < load[pool[j]] })
+	return pool[:max(0, min(limit, len(pool)))], nil
+}
+
+/*
+weightedSelector выбирает ревьюверов случайно без повторов, где вероятность
+пропорциональна весу пользователя. Вес по умолчанию равен 1,
+пользователи с неположительным весом не назначаются.
+*/
+type weightedSelector struct {
+	weights map[string]int
+}
+
+func (s *weightedSelector) SelectReviewers(ctx context.Context, team string, candidates []model.ReviewCandidate, limit int) ([]string, error) {
+	return weightedSample(Rand(ctx), withCapacity(candidates), limit, func(id string) float64 {
+		w, ok := s.weights[id]
+		if !ok {
+			w = 1
+		}
+		return float64(w)
+	}), nil
+}
+
+/*
+rotationSelector выбирает ревьюверов случайно, но реже назначает тех,
+кто ревьюил последние PR автора (PRAuthor из контекста), чтобы знания
+о коде расходились по команде. Окно — rotation_window последних PR автора
+с ревьюверами (настройка команды автора, а не той, из которой выбираются
+кандидаты: в резервной команде окно то же). Ревьювер PR на позиции i
+(0 — самый свежий) получает штраф rotation_window - i; вес кандидата —
+1 / (1 + сумма штрафов). Без автора или при нулевом окне выбор равновероятный.
+*/
+type rotationSelector struct {
+	repo Repo
+}
+
+func (s *rotationSelector) SelectReviewers(ctx context.Context, team string, candidates []model.ReviewCandidate, limit int) ([]string, error) {
+	penalty, err := s.penalties(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return weightedSample(Rand(ctx), withCapacity(candidates), limit, func(id string) float64 {
+		return 1 / (1 + float64(penalty[id]))
+	}), nil
+}
+
+// penalties считает штрафы недавних ревьюверов автора в окне команды автора.
+func (s *rotationSelector) penalties(ctx context.Context) (map[string]int, error) {
+	penalty := map[string]int{}
+	author := PRAuthor(ctx)
+	if author == "" {
+		return penalty, nil
+	}
+
+	user, err := s.repo.GetUserByID(ctx, author)
+	if err != nil {
+		return nil, err
+	}
+	ts, err := s.repo.GetTeamSettings(ctx, user.TeamName)
+	if err != nil {
+		return nil, err
+	}
+	if ts.RotationWindow <= 0 {
+		return penalty, nil
+	}
+
+	recent, err := s.repo.GetRecentReviewers(ctx, author, ts.RotationWindow)
+	if err != nil {
+		return nil, err
+	}
+	for i, revs := range recent {
+		for _, uid := range revs {
+			penalty[uid] += ts.RotationWindow - i
+		}
+	}
+	return penalty, nil
+}
+
+/*
+weightedSample выбирает до limit идентификаторов из pool случайно (источник rnd)
+без повторов с вероятностью, пропорциональной weight. Идентификаторы
+с неположительным весом не выбираются.
+*/
+func weightedSample(rnd *rand.Rand, pool []string, limit int, weight func(id string) float64) []string {
+	// Взвешенная выборка без возвращения (Efraimidis–Spirakis):
+	// у каждого кандидата ключ u^(1/w), берём limit наибольших.
+	type keyed struct {
+		id  string
+		key float64
+	}
+	items := make([]keyed, 0, len(pool))
+	for _, id := range pool {
+		w := weight(id)
+		if w <= 0 {
+			continue
+		}
+		items = append(items, keyed{id: id, key: math.Pow(rnd.Float64(), 1/w)})
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].key > items[j].key })
+
+	result := []string{}
+	for i := 0; i < len(items) && i < limit; i++ {
+		result = append(result, items[i].id)
+	}
+	return result
+}
+
+// withCapacity оставляет user_id кандидатов, которые могут взять ещё одно ревью.
+func withCapacity(candidates []model.ReviewCandidate) []string {
+	result := []string{}
+	for _, c := range candidates {
+		if c.HasCapacity() {
+			result = append(result, c.UserID)
+		}
+	}
+	return result
+}
+
+// newSelectors создаёт набор встроенных стратегий.
+func newSelectors(r Repo, cfg Config) map[Strategy]ReviewerSelector {
+	return map[Strategy]ReviewerSelector{
+		StrategyRandom:      &randomSelector{},
+		StrategyRoundRobin:  &roundRobinSelector{last: map[string]string{}},
+		StrategyLeastLoaded: &leastLoadedSelector{},
+		StrategyWeighted:    &weightedSelector{weights: cfg.Weights},
+		StrategyRotation:    &rotationSelector{repo: r},
+	}
+}
+
+/*
+RegisterSelector добавляет пользовательскую стратегию или заменяет встроенную.
+Должен вызываться до начала обработки запросов.
+*/
+func (s *Service) RegisterSelector(name Strategy, sel ReviewerSelector) {
+	s.selectors[name] = sel
+}
+
+/*
+strategyFor возвращает имя стратегии команды с учётом переопределений;
+неизвестная стратегия заменяется random.
+*/
+func (s *Service) strategyFor(team string) Strategy {
+	name := s.cfg.DefaultStrategy
+	if st, ok := s.cfg.TeamStrategies[team]; ok {
+		name = st
+	}
+	if _, ok := s.selectors[name]; ok {
+		return name
+	}
+	return StrategyRandom
+}
+
+/*
+selectReviewers выбирает ревьюверов стратегией команды и записывает шаг
+выбора с причиной reason (TEAM или FALLBACK_TEAM). Если никого выбрать
+не удалось, а все активные кандидаты команды исчерпали лимит, возвращается
+ErrAtCapacity. Если стратегия отсеяла кандидатов с запасом лимита
+(например, нулевым весом в weighted), возвращается пустой список.
+*/
+func (s *Service) selectReviewers(ctx context.Context, team string, reason model.MatchReason, limit int, exclude []string) ([]string, error) {
+	candidates, err := s.repo.GetReviewCandidates(ctx, team, exclude)
+	if err != nil {
+		return nil, err
+	}
+
+	strategy := s.strategyFor(team)
+	revs, err := s.selectors[strategy].SelectReviewers(ctx, team, candidates, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	if limit > 0 {
+		step := candidateStep(reason, team, candidates)
+		step.Strategy = string(strategy)
+		step.Chosen = append(step.Chosen, revs...)
+		recordStep(ctx, step)
+	}
+
+	if len(revs) > 0 || limit <= 0 {
+		return revs, nil
+	}
+	if len(candidates) > 0 && len(withCapacity(candidates)) == 0 {
+		return nil, ErrAtCapacity
+	}
+	return revs, nil
+}
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+
+	"pr-review-service/internal/model"
+)
+
+// normalizeLevel подставляет уровень по умолчанию и проверяет известные уровни.
+func normalizeLevel(l model.Level) (model.Level, error) {
+	if l == "" {
+		return model.DefaultLevel, nil
+	}
+	if !l.Valid() {
+		return "", ErrInvalid
+	}
+	return l, nil
+}
+
+// hasSenior сообщает, есть ли среди пользователей ids старший (senior или lead).
+func (s *Service) hasSenior(ctx context.Context, ids []string) (bool, error) {
+	for _, uid := range ids {
+		u, err := s.repo.GetUserByID(ctx, uid)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+		if u.Level.IsSenior() {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+/*
+needSenior сообщает, нужно ли добавить к ревьюверам revs старшего:
+команда автора требует его (require_senior), а среди revs старших нет.
+*/
+func (s *Service) needSenior(ctx context.Context, ts *model.TeamSettings, revs []string) (bool, error) {
+	if !ts.RequireSenior {
+		return false, nil
+	}
+	ok, err := s.hasSenior(ctx, revs)
+	if err != nil {
+		return false, err
+	}
+	return !ok, nil
+}
+
+/*
+selectSenior выбирает старшего ревьювера из команды team, а если там
+никого нет — из её резервных команд по порядку. Среди доступных старших
+с запасом лимита предпочтение — большему совпадению тегов с labels,
+затем меньшей нагрузке, затем user_id. Возвращает "", если выбрать некого.
+Для каждой просмотренной команды записывается шаг выбора SENIOR.
+*/
+func (s *Service) selectSenior(ctx context.Context, team string, labels, exclude []string) (string, model.ReviewerOrigin, error) {
+	ts, err := s.repo.GetTeamSettings(ctx, team)
+	if err != nil {
+		return "", model.ReviewerOrigin{}, err
+	}
+
+	for i, t := range append([]string{team}, ts.FallbackTeams...) {
+		candidates, err := s.repo.GetReviewCandidates(ctx, t, exclude)
+		if err != nil {
+			return "", model.ReviewerOrigin{}, err
+		}
+
+		seniors, free := []model.ReviewCandidate{}, []model.ReviewCandidate{}
+		for _, c := range candidates {
+			if !c.Level.IsSenior() {
+				continue
+			}
+			seniors = append(seniors, c)
+			if c.HasCapacity() {
+				free = append(free, c)
+			}
+		}
+		step := candidateStep(model.MatchSenior, t, seniors)
+		if len(free) == 0 {
+			recordStep(ctx, step)
+			continue
+		}
+
+		sort.Slice(free, func(i, j int) bool {
+			a, b := free[i], free[j]
+			ma, mb := len(matchTags(a.Tags, labels)), len(matchTags(b.Tags, labels))
+			if ma != mb {
+				return ma > mb
+			}
+			if a.OpenReviews != b.OpenReviews {
+				return a.OpenReviews < b.OpenReviews
+			}
+			return a.UserID < b.UserID
+		})
+
+		picked := free[0]
+		step.Chosen = append(step.Chosen, picked.UserID)
+		recordStep(ctx, step)
+
+		o := model.ReviewerOrigin{MatchReason: model.MatchSenior, MatchedTags: matchTags(picked.Tags, labels)}
+		if i > 0 {
+			o.FallbackTeam = t
+		}
+		return picked.UserID, o, nil
+	}
+	return "", model.ReviewerOrigin{}, nil
+}
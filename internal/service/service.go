@@ -4,6 +4,9 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"math/rand"
+	"strings"
+	"sync"
 	"time"
 
 	"pr-review-service/internal/model"
@@ -17,27 +20,83 @@ var (
 	ErrTeamExists  = errors.New("team_exists")
 	ErrPRExists    = errors.New("pr_exists")
 	ErrPRMerged    = errors.New("pr_merged")
+	ErrPRClosed    = errors.New("pr_closed")
+	ErrPRDraft     = errors.New("pr_draft")
 	ErrNotAssigned = errors.New("not_assigned")
 	ErrNoCandidate = errors.New("no_candidate")
 	ErrNotFound    = errors.New("not_found")
+	ErrAtCapacity  = errors.New("at_capacity")
+	ErrInvalid     = errors.New("invalid_argument")
+	ErrNotApproved = errors.New("not_approved")
+
+	ErrUserInactive    = errors.New("user_inactive")
+	ErrUserUnavailable = errors.New("user_unavailable")
+	ErrIsAuthor        = errors.New("is_author")
+	ErrAlreadyAssigned = errors.New("already_assigned")
+	ErrNotInTeam       = errors.New("not_in_team")
+
+	ErrMaxReviewers = errors.New("max_reviewers")
+	ErrMinReviewers = errors.New("min_reviewers")
+
+	// ErrSeniorRequired — команда автора требует старшего ревьювера,
+	// а операция оставила бы PR без него.
+	ErrSeniorRequired = errors.New("senior_required")
 )
 
+/*
+NotApprovedError возвращается MergePR, если PR не проходит политику
+мерджа команды автора. errors.Is(err, ErrNotApproved) для неё истинно.
+*/
+type NotApprovedError struct {
+	Status model.ApprovalStatus
+}
+
+func (e *NotApprovedError) Error() string { return ErrNotApproved.Error() }
+
+func (e *NotApprovedError) Unwrap() error { return ErrNotApproved }
+
 // Интерфейс репозитория
 type Repo interface {
+	// InTx выполняет fn в одной транзакции: методы, вызванные
+	// с переданным в fn контекстом, видят и фиксируют изменения вместе.
+	InTx(ctx context.Context, fn func(ctx context.Context) error) error
+
 	CreateTeamWithMembers(ctx context.Context, t model.Team) error
 	GetTeam(ctx context.Context, name string) (*model.Team, error)
+	GetTeamSettings(ctx context.Context, name string) (*model.TeamSettings, error)
+	UpdateTeamSettings(ctx context.Context, ts model.TeamSettings) error
+	GetOwnershipRules(ctx context.Context, team string) ([]model.OwnershipRule, error)
+	SetOwnershipRules(ctx context.Context, team string, rules []model.OwnershipRule) error
 
 	GetUserByID(ctx context.Context, id string) (*model.User, error)
 	UpdateUserIsActive(ctx context.Context, id string, active bool) (*model.User, error)
+	UpdateUser(ctx context.Context, id string, upd model.UserUpdate) (*model.User, error)
+
+	CreateUnavailability(ctx context.Context, w model.UnavailabilityWindow) (*model.UnavailabilityWindow, error)
+	ListUnavailability(ctx context.Context, uid string) ([]model.UnavailabilityWindow, error)
+	DeleteUnavailability(ctx context.Context, id int64) error
 
 	PRExists(ctx context.Context, id string) (bool, error)
 	CreatePullRequest(ctx context.Context, pr model.PullRequest) error
 	GetPullRequestWithReviewers(ctx context.Context, id string) (*model.PullRequest, error)
-	SetPRMerged(ctx context.Context, id string, mergedAt sql.NullTime) (*model.PullRequest, error)
+
+	// LockPullRequest блокирует PR до конца транзакции InTx, чтобы проверки
+	// и запись его ревьюверов не перемежались с другими транзакциями.
+	LockPullRequest(ctx context.Context, id string) error
+	SetPRMerged(ctx context.Context, id string, mergedAt sql.NullTime, override bool) (*model.PullRequest, error)
+	SetPRStatus(ctx context.Context, id string, status model.PullRequestStatus, at time.Time) error
 	SetPRReviewers(ctx context.Context, id string, reviewers []string) error
+	SetReviewState(ctx context.Context, prID, userID string, state model.ReviewState, at time.Time) error
+	SetReviewerOrigin(ctx context.Context, prID, userID string, o model.ReviewerOrigin) error
 
-	GetRandomActiveReviewersFromTeamExcluding(ctx context.Context, team string, limit int, exclude []string) ([]string, error)
+	GetReviewCandidates(ctx context.Context, team string, exclude []string) ([]model.ReviewCandidate, error)
 	GetPullRequestsByReviewer(ctx context.Context, uid string) ([]model.PullRequestShort, error)
+	ListPullRequests(ctx context.Context, f model.PullRequestFilter) ([]model.PullRequestShort, error)
+	GetUnderstaffedPullRequests(ctx context.Context, team string, want int) ([]string, error)
+	GetRecentReviewers(ctx context.Context, author string, n int) ([][]string, error)
+
+	CreateAssignment(ctx context.Context, a model.Assignment) (*model.Assignment, error)
+	ListAssignments(ctx context.Context, prID string) ([]model.Assignment, error)
 
 	GetReviewerAssignmentStats(ctx context.Context) ([]model.ReviewerStat, error)
 }
@@ -48,26 +107,76 @@ Service инкапсулирует бизнес-логику и использу
 */
 type Service struct {
 	repo Repo
+
+	cfg       Config
+	selectors map[Strategy]ReviewerSelector
+
+	// seeds выдаёт зёрна для выбора ревьюверов, next — зерно следующего
+	// выбора (см. startAssignment).
+	mu    sync.Mutex
+	seeds *rand.Rand
+	next  int64
 }
 
-func NewService(r Repo) *Service {
-	return &Service{repo: r}
+func NewService(r Repo, cfg Config) *Service {
+	if cfg.DefaultStrategy == "" {
+		cfg.DefaultStrategy = StrategyRandom
+	}
+	src := cfg.Rand
+	if src == nil {
+		src = rand.NewSource(time.Now().UnixNano())
+	}
+	seeds := rand.New(src)
+	return &Service{
+		repo:      r,
+		cfg:       cfg,
+		selectors: newSelectors(r, cfg),
+		seeds:     seeds,
+		next:      seeds.Int63(),
+	}
 }
 
 /*
 CreateTeam создаёт новую команду вместе с её участниками.
 
+Участники, которые уже были в других командах, переходят в новую вместе
+со своими открытыми PR; этим PR доназначаются ревьюверы из новой команды
+(см. topUpTeam), результат возвращается вторым значением.
+
 Эндпоинт: POST /team/add
 */
-func (s *Service) CreateTeam(ctx context.Context, t model.Team) (*model.Team, error) {
-	err := s.repo.CreateTeamWithMembers(ctx, t)
+func (s *Service) CreateTeam(ctx context.Context, t model.Team) (*model.Team, []model.ReviewerFill, error) {
+	for i, m := range t.Members {
+		if m.MaxOpenReviews < 0 {
+			return nil, nil, ErrInvalid
+		}
+		tags, err := normalizeTags(m.Tags)
+		if err != nil {
+			return nil, nil, err
+		}
+		t.Members[i].Tags = tags
+		if t.Members[i].Level, err = normalizeLevel(m.Level); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var fills []model.ReviewerFill
+	err := s.repo.InTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.CreateTeamWithMembers(ctx, t); err != nil {
+			return err
+		}
+
+		var err error
+		fills, err = s.topUpTeam(ctx, t.TeamName)
+		return err
+	})
 	if err != nil {
 		if err.Error() == "team_exists" {
-			return nil, ErrTeamExists
+			return nil, nil, ErrTeamExists
 		}
-		return nil, err
+		return nil, nil, err
 	}
-	return &t, nil
+	return &t, fills, nil
 }
 
 /*
@@ -86,13 +195,229 @@ func (s *Service) GetTeam(ctx context.Context, name string) (*model.Team, error)
 	return t, nil
 }
 
+/*
+GetTeamSettings возвращает настройки команды.
+
+Эндпоинт: GET /team/settings?team_name=...
+*/
+func (s *Service) GetTeamSettings(ctx context.Context, name string) (*model.TeamSettings, error) {
+	ts, err := s.repo.GetTeamSettings(ctx, name)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return ts, nil
+}
+
+/*
+UpdateTeamSettings сохраняет настройки команды.
+
+Числовые настройки не могут быть отрицательными, а reviewer_count
+должен лежать в пределах [min_reviewers, max_reviewers]
+(max_reviewers = 0 снимает верхнюю границу), а при require_senior
+быть не меньше 1. Резервные команды должны
+существовать, не повторяться и не совпадать с самой командой.
+
+Эндпоинт: POST /team/settings
+*/
+func (s *Service) UpdateTeamSettings(ctx context.Context, ts model.TeamSettings) (*model.TeamSettings, error) {
+	if ts.MinApprovals < 0 || ts.ReviewerCount < 0 || ts.MinReviewers < 0 || ts.MaxReviewers < 0 || ts.RotationWindow < 0 {
+		return nil, ErrInvalid
+	}
+	if ts.ReviewerCount < ts.MinReviewers || (ts.MaxReviewers > 0 && ts.ReviewerCount > ts.MaxReviewers) {
+		return nil, ErrInvalid
+	}
+	if ts.RequireSenior && ts.ReviewerCount == 0 {
+		return nil, ErrInvalid
+	}
+	if err := s.checkFallbackTeams(ctx, ts); err != nil {
+		return nil, err
+	}
+	if ts.FallbackTeams == nil {
+		ts.FallbackTeams = []string{}
+	}
+
+	err := s.repo.UpdateTeamSettings(ctx, ts)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &ts, nil
+}
+
+// checkFallbackTeams проверяет список резервных команд из настроек ts.
+func (s *Service) checkFallbackTeams(ctx context.Context, ts model.TeamSettings) error {
+	seen := map[string]bool{ts.TeamName: true}
+	for _, fb := range ts.FallbackTeams {
+		if seen[fb] {
+			return ErrInvalid
+		}
+		seen[fb] = true
+
+		if _, err := s.repo.GetTeamSettings(ctx, fb); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrInvalid
+			}
+			return err
+		}
+	}
+	return nil
+}
+
 /*
 SetIsActive обновляет флаг активности пользователя.
 
+При деактивации, если reassign равен true (или nil и в команде включён
+auto_reassign_on_deactivate), все открытые ревью пользователя переназначаются
+по тем же правилам, что и в ReassignReviewer, в одной транзакции.
+В этом случае возвращается отчёт о переназначении, иначе nil.
+
+При активации открытым PR команды пользователя, которым не хватает
+ревьюверов, они доназначаются (см. topUpTeam); список доназначений
+возвращается третьим значением.
+
 Эндпоинт: POST /users/setIsActive.
 */
-func (s *Service) SetUserIsActive(ctx context.Context, uid string, active bool) (*model.User, error) {
-	u, err := s.repo.UpdateUserIsActive(ctx, uid, active)
+func (s *Service) SetUserIsActive(ctx context.Context, uid string, active bool, reassign *bool) (*model.User, *model.ReassignReport, []model.ReviewerFill, error) {
+	if active {
+		var (
+			u     *model.User
+			fills []model.ReviewerFill
+		)
+		err := s.repo.InTx(ctx, func(ctx context.Context) error {
+			var err error
+			u, err = s.repo.UpdateUserIsActive(ctx, uid, true)
+			if err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					return ErrNotFound
+				}
+				return err
+			}
+
+			fills, err = s.topUpTeam(ctx, u.TeamName)
+			return err
+		})
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		return u, nil, fills, nil
+	}
+
+	doReassign := false
+	if reassign != nil {
+		doReassign = *reassign
+	} else {
+		u, err := s.repo.GetUserByID(ctx, uid)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, nil, nil, ErrNotFound
+			}
+			return nil, nil, nil, err
+		}
+		ts, err := s.repo.GetTeamSettings(ctx, u.TeamName)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		doReassign = ts.AutoReassignOnDeactivate
+	}
+
+	if !doReassign {
+		u, err := s.repo.UpdateUserIsActive(ctx, uid, false)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, nil, nil, ErrNotFound
+			}
+			return nil, nil, nil, err
+		}
+		return u, nil, nil, nil
+	}
+
+	var u *model.User
+	report := &model.ReassignReport{
+		Reassigned:  []model.ReviewReassignment{},
+		NoCandidate: []model.UnassignedReview{},
+	}
+	err := s.repo.InTx(ctx, func(ctx context.Context) error {
+		var err error
+		u, err = s.repo.UpdateUserIsActive(ctx, uid, false)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrNotFound
+			}
+			return err
+		}
+
+		prs, err := s.repo.GetPullRequestsByReviewer(ctx, uid)
+		if err != nil {
+			return err
+		}
+
+		for _, p := range prs {
+			if p.Status != model.PRStatusOpen {
+				continue
+			}
+
+			_, newReviewer, err := s.ReassignReviewer(ctx, p.ID, uid, "", false)
+			switch {
+			case err == nil:
+				report.Reassigned = append(report.Reassigned, model.ReviewReassignment{
+					PullRequestID: p.ID,
+					ReplacedBy:    newReviewer,
+				})
+			case errors.Is(err, ErrNoCandidate):
+				report.NoCandidate = append(report.NoCandidate, model.UnassignedReview{
+					PullRequestID: p.ID,
+					Reason:        "NO_CANDIDATE",
+				})
+			case errors.Is(err, ErrAtCapacity):
+				report.NoCandidate = append(report.NoCandidate, model.UnassignedReview{
+					PullRequestID: p.ID,
+					Reason:        "AT_CAPACITY",
+				})
+			case errors.Is(err, ErrSeniorRequired):
+				report.NoCandidate = append(report.NoCandidate, model.UnassignedReview{
+					PullRequestID: p.ID,
+					Reason:        "SENIOR_REQUIRED",
+				})
+			default:
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return u, report, nil, nil
+}
+
+/*
+UpdateUser частично обновляет пользователя (имя, лимит открытых ревью,
+теги экспертизы, уровень). Теги заменяются целиком и приводятся к нижнему регистру.
+
+Эндпоинт: POST /users/update.
+*/
+func (s *Service) UpdateUser(ctx context.Context, uid string, upd model.UserUpdate) (*model.User, error) {
+	if upd.MaxOpenReviews != nil && *upd.MaxOpenReviews < 0 {
+		return nil, ErrInvalid
+	}
+	if upd.Tags != nil {
+		tags, err := normalizeTags(*upd.Tags)
+		if err != nil {
+			return nil, err
+		}
+		upd.Tags = &tags
+	}
+	if upd.Level != nil && !upd.Level.Valid() {
+		return nil, ErrInvalid
+	}
+
+	u, err := s.repo.UpdateUser(ctx, uid, upd)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrNotFound
@@ -103,11 +428,80 @@ func (s *Service) SetUserIsActive(ctx context.Context, uid string, active bool)
 }
 
 /*
-CreatePullRequest создаёт новый PR и автоматически назначает ревьюверов.
+AddUnavailability добавляет пользователю окно недоступности. Пока окно
+действует, пользователь не назначается ревьювером.
+
+Эндпоинт: POST /users/addUnavailability.
+*/
+func (s *Service) AddUnavailability(ctx context.Context, w model.UnavailabilityWindow) (*model.UnavailabilityWindow, error) {
+	if !w.EndsAt.After(w.StartsAt) {
+		return nil, ErrInvalid
+	}
+
+	if _, err := s.repo.GetUserByID(ctx, w.UserID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	w.StartsAt = w.StartsAt.UTC()
+	w.EndsAt = w.EndsAt.UTC()
+	return s.repo.CreateUnavailability(ctx, w)
+}
+
+/*
+ListUnavailability возвращает текущие и будущие окна недоступности пользователя.
+
+Эндпоинт: GET /users/getUnavailability?user_id=...
+*/
+func (s *Service) ListUnavailability(ctx context.Context, uid string) ([]model.UnavailabilityWindow, error) {
+	if _, err := s.repo.GetUserByID(ctx, uid); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return s.repo.ListUnavailability(ctx, uid)
+}
+
+/*
+DeleteUnavailability удаляет окно недоступности.
+
+Эндпоинт: POST /users/deleteUnavailability.
+*/
+func (s *Service) DeleteUnavailability(ctx context.Context, id int64) error {
+	err := s.repo.DeleteUnavailability(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
+
+/*
+CreatePullRequest создаёт новый PR и автоматически назначает reviewer_count
+ревьюверов стратегией команды автора. Если переданы изменённые файлы,
+сначала назначаются их владельцы по CODEOWNERS команды автора (selectOwners),
+а остальные места добираются из команды — в первую очередь участниками,
+чьи теги совпадают с метками PR (selectByTags). Если подходящих кандидатов меньше,
+PR создаётся с теми, что нашлись, и в ответе помечается understaffed.
+Черновик (draft) создаётся в статусе DRAFT без ревьюверов — они
+назначаются при переводе в OPEN (MarkReady). Как выбраны ревьюверы,
+объясняет GetAssignments.
 
 Эндпоинт: POST /pullRequest/create.
 */
-func (s *Service) CreatePR(ctx context.Context, id, name, author string) (*model.PullRequest, error) {
+func (s *Service) CreatePR(ctx context.Context, req model.NewPullRequest) (*model.PullRequest, error) {
+	id, author := req.ID, req.AuthorID
+	paths, err := normalizePaths(req.ChangedPaths)
+	if err != nil {
+		return nil, err
+	}
+	labels, err := normalizeTags(req.Labels)
+	if err != nil {
+		return nil, err
+	}
+
 	exists, err := s.repo.PRExists(ctx, id)
 	if err != nil {
 		return nil, err
@@ -116,44 +510,155 @@ func (s *Service) CreatePR(ctx context.Context, id, name, author string) (*model
 		return nil, ErrPRExists
 	}
 
-	user, err := s.repo.GetUserByID(ctx, author)
-	if err != nil {
+	if _, err := s.repo.GetUserByID(ctx, author); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrNotFound
 		}
 		return nil, err
 	}
 
-	exclude := []string{author}
-	revs, err := s.repo.GetRandomActiveReviewersFromTeamExcluding(ctx, user.TeamName, 2, exclude)
+	var created *model.PullRequest
+	err = s.repo.InTx(ctx, func(ctx context.Context) error {
+		status := model.PRStatusDraft
+		var a assignment
+		if !req.Draft {
+			status = model.PRStatusOpen
+			a, err = s.initialReviewers(ctx, author, paths, labels)
+			if err != nil {
+				return err
+			}
+		}
+
+		now := time.Now().UTC()
+		pr := model.PullRequest{
+			ID:                id,
+			Name:              req.Name,
+			AuthorID:          author,
+			Status:            status,
+			AssignedReviewers: a.reviewers,
+			CreatedAt:         &now,
+			ChangedPaths:      paths,
+			Labels:            labels,
+		}
+
+		if err := s.repo.CreatePullRequest(ctx, pr); err != nil {
+			return err
+		}
+		if err := s.saveOrigins(ctx, id, a.origins); err != nil {
+			return err
+		}
+		if a.trace != nil {
+			if err := s.saveAssignment(ctx, id, model.AssignmentCreate, a.trace, a.reviewers); err != nil {
+				return err
+			}
+		}
+
+		created, err = s.repo.GetPullRequestWithReviewers(ctx, id)
+		if err != nil {
+			return err
+		}
+		created.Understaffed = len(created.AssignedReviewers) < a.want
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
+	return created, nil
+}
 
-	now := time.Now().UTC()
-	pr := model.PullRequest{
-		ID:                id,
-		Name:              name,
-		AuthorID:          author,
-		Status:            model.PRStatusOpen,
-		AssignedReviewers: revs,
-		CreatedAt:         &now,
+/*
+initialReviewers выбирает ревьюверов для PR, который переходит в OPEN:
+владельцев изменённых файлов paths, затем старшего, если его требует
+команда автора и среди владельцев его нет (selectSenior), затем до
+reviewer_count команды автора с предпочтением тегов, совпавших с labels,
+при нехватке — из её резервных команд. Владельцев может оказаться больше
+reviewer_count, а всех выбранных — меньше. Если старший нужен, но выбрать
+его некого, возвращается ErrSeniorRequired. Шаги выбора записываются
+в assignment.trace.
+*/
+func (s *Service) initialReviewers(ctx context.Context, author string, paths, labels []string) (assignment, error) {
+	user, ts, err := s.authorSettings(ctx, author)
+	if err != nil {
+		return assignment{}, err
 	}
+	ctx, tr := s.startAssignment(ctx, []string{author})
 
-	err = s.repo.CreatePullRequest(ctx, pr)
+	revs, origins, err := s.selectOwners(ctx, user.TeamName, author, paths)
 	if err != nil {
-		return nil, err
+		return assignment{}, err
+	}
+
+	need, err := s.needSenior(ctx, ts, revs)
+	if err != nil {
+		return assignment{}, err
+	}
+	if need {
+		senior, o, err := s.selectSenior(ctx, user.TeamName, labels, append([]string{author}, revs...))
+		if err != nil {
+			return assignment{}, err
+		}
+		if senior == "" {
+			return assignment{}, ErrSeniorRequired
+		}
+		revs = append(revs, senior)
+		origins[senior] = o
 	}
 
-	return &pr, nil
+	exclude := append([]string{author}, revs...)
+	rest, restOrigins, err := s.selectForPR(ctx, author, user.TeamName, labels, ts.ReviewerCount-len(revs), exclude)
+	if err != nil && !(errors.Is(err, ErrAtCapacity) && len(revs) > 0) {
+		return assignment{}, err
+	}
+	revs = append(revs, rest...)
+	for uid, o := range restOrigins {
+		origins[uid] = o
+	}
+	return assignment{reviewers: revs, origins: origins, want: ts.ReviewerCount, trace: tr}, nil
+}
+
+// normalizePaths отбрасывает повторы в списке изменённых файлов; пустой путь недопустим.
+func normalizePaths(paths []string) ([]string, error) {
+	seen := map[string]bool{}
+	result := []string{}
+	for _, p := range paths {
+		if strings.TrimSpace(p) == "" {
+			return nil, ErrInvalid
+		}
+		if !seen[p] {
+			seen[p] = true
+			result = append(result, p)
+		}
+	}
+	return result, nil
+}
+
+// authorSettings возвращает автора PR и настройки его команды.
+func (s *Service) authorSettings(ctx context.Context, author string) (*model.User, *model.TeamSettings, error) {
+	user, err := s.repo.GetUserByID(ctx, author)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil, ErrNotFound
+		}
+		return nil, nil, err
+	}
+	ts, err := s.repo.GetTeamSettings(ctx, user.TeamName)
+	if err != nil {
+		return nil, nil, err
+	}
+	return user, ts, nil
 }
 
 /*
 MergePullRequest переводит PR в статус MERGED.
 
+Мердж проверяется по политике команды автора (min_approvals,
+block_on_changes_requested); при нарушении возвращается *NotApprovedError.
+override позволяет замёрджить PR в обход политики — такой мердж
+помечается в PR флагом merge_override.
+
 Эндпоинт: POST /pullRequest/merge.
 */
-func (s *Service) MergePR(ctx context.Context, id string) (*model.PullRequest, error) {
+func (s *Service) MergePR(ctx context.Context, id string, override bool) (*model.PullRequest, error) {
 	pr, err := s.repo.GetPullRequestWithReviewers(ctx, id)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -162,78 +667,382 @@ func (s *Service) MergePR(ctx context.Context, id string) (*model.PullRequest, e
 		return nil, err
 	}
 
-	if pr.Status == model.PRStatusMerged {
+	done, err := transitionMerge.check(pr)
+	if err != nil {
+		return nil, err
+	}
+	if done {
 		return pr, nil
 	}
 
+	status, approved, err := s.approvalStatus(ctx, pr)
+	if err != nil {
+		return nil, err
+	}
+	if !approved && !override {
+		return nil, &NotApprovedError{Status: status}
+	}
+
 	now := sql.NullTime{Time: time.Now().UTC(), Valid: true}
-	return s.repo.SetPRMerged(ctx, id, now)
+	return s.repo.SetPRMerged(ctx, id, now, !approved)
+}
+
+// approvalStatus проверяет PR по политике мерджа команды автора.
+func (s *Service) approvalStatus(ctx context.Context, pr *model.PullRequest) (model.ApprovalStatus, bool, error) {
+	status := model.ApprovalStatus{MissingApprovals: []string{}, ChangesRequested: []string{}}
+
+	_, ts, err := s.authorSettings(ctx, pr.AuthorID)
+	if err != nil {
+		return status, false, err
+	}
+
+	status.RequiredApprovals = ts.MinApprovals
+	for _, rs := range pr.Reviews {
+		switch rs.State {
+		case model.ReviewApproved:
+			status.Approvals++
+			continue
+		case model.ReviewChangesRequested:
+			status.ChangesRequested = append(status.ChangesRequested, rs.UserID)
+		}
+		status.MissingApprovals = append(status.MissingApprovals, rs.UserID)
+	}
+
+	approved := status.Approvals >= ts.MinApprovals
+	if ts.BlockOnChangesRequested && len(status.ChangesRequested) > 0 {
+		approved = false
+	}
+	return status, approved, nil
 }
 
 /*
-ReassignReviewer заменяет одного ревьювера случайным активным пользователем
-из команды старого ревьювера.
+ReassignReviewer заменяет одного ревьювера активным пользователем
+из команды старого ревьювера: участником с тегами, совпавшими с метками PR,
+а если таких нет — выбранным стратегией этой команды. Если в ней
+кандидатов нет, замена ищется в её резервных командах.
+
+Если задан newUser, замена не выбирается стратегией: указанный пользователь
+проверяется так же, как в AddReviewer, а при sameTeam он ещё должен
+состоять в команде старого ревьювера.
+
+Если команда автора требует старшего ревьювера, а кроме old старших
+на PR нет, замена должна быть старшей (см. selectSenior); иначе
+возвращается ErrSeniorRequired.
+
+Автоматический выбор замены сохраняется как назначение REASSIGN.
 
 Эндпоинт: POST /pullRequest/reassign.
 */
-func (s *Service) ReassignReviewer(ctx context.Context, prID, old string) (*model.PullRequest, string, error) {
-	pr, err := s.repo.GetPullRequestWithReviewers(ctx, prID)
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, "", ErrNotFound
+func (s *Service) ReassignReviewer(ctx context.Context, prID, old, newUser string, sameTeam bool) (*model.PullRequest, string, error) {
+	var (
+		pr          *model.PullRequest
+		newReviewer string
+	)
+	err := s.repo.InTx(ctx, func(ctx context.Context) error {
+		cur, err := s.lockPR(ctx, prID)
+		if err != nil {
+			return err
+		}
+		if err := requireOpen(cur); err != nil {
+			return err
+		}
+
+		assigned := false
+		others := []string{}
+		for _, r := range cur.AssignedReviewers {
+			if r == old {
+				assigned = true
+			} else {
+				others = append(others, r)
+			}
+		}
+		if !assigned {
+			return ErrNotAssigned
+		}
+
+		oldUser, err := s.repo.GetUserByID(ctx, old)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrNotFound
+			}
+			return err
+		}
+
+		_, ts, err := s.authorSettings(ctx, cur.AuthorID)
+		if err != nil {
+			return err
+		}
+		needSenior, err := s.needSenior(ctx, ts, others)
+		if err != nil {
+			return err
+		}
+
+		var (
+			origins map[string]model.ReviewerOrigin
+			tr      *assignmentTrace
+		)
+		if newUser != "" {
+			user, err := s.checkNewReviewer(ctx, cur, newUser)
+			if err != nil {
+				return err
+			}
+			if sameTeam && user.TeamName != oldUser.TeamName {
+				return ErrNotInTeam
+			}
+			if needSenior && !user.Level.IsSenior() {
+				return ErrSeniorRequired
+			}
+			newReviewer = newUser
+			origins = map[string]model.ReviewerOrigin{newUser: {MatchReason: model.MatchManual}}
+		} else if needSenior {
+			exclude := append([]string{old, cur.AuthorID}, cur.AssignedReviewers...)
+			ctx, tr = s.startAssignment(ctx, exclude)
+
+			senior, o, err := s.selectSenior(ctx, oldUser.TeamName, cur.Labels, exclude)
+			if err != nil {
+				return err
+			}
+			if senior == "" {
+				return ErrSeniorRequired
+			}
+
+			newReviewer = senior
+			origins = map[string]model.ReviewerOrigin{senior: o}
+		} else {
+			exclude := append([]string{old, cur.AuthorID}, cur.AssignedReviewers...)
+			ctx, tr = s.startAssignment(ctx, exclude)
+
+			candidates, found, err := s.selectForPR(
+				ctx,
+				cur.AuthorID,
+				oldUser.TeamName,
+				cur.Labels,
+				1,
+				exclude,
+			)
+			if err != nil {
+				return err
+			}
+
+			if len(candidates) == 0 {
+				return ErrNoCandidate
+			}
+
+			newReviewer = candidates[0]
+			origins = found
 		}
+
+		for i := range cur.AssignedReviewers {
+			if cur.AssignedReviewers[i] == old {
+				cur.AssignedReviewers[i] = newReviewer
+			}
+		}
+
+		if err := s.repo.SetPRReviewers(ctx, prID, cur.AssignedReviewers); err != nil {
+			return err
+		}
+		if err := s.saveOrigins(ctx, prID, origins); err != nil {
+			return err
+		}
+		if tr != nil {
+			if err := s.saveAssignment(ctx, prID, model.AssignmentReassign, tr, []string{newReviewer}); err != nil {
+				return err
+			}
+		}
+
+		pr, err = s.repo.GetPullRequestWithReviewers(ctx, prID)
+		return err
+	})
+	if err != nil {
 		return nil, "", err
 	}
 
-	if pr.Status == model.PRStatusMerged {
-		return nil, "", ErrPRMerged
-	}
+	return pr, newReviewer, nil
+}
 
-	assigned := false
-	for _, r := range pr.AssignedReviewers {
-		if r == old {
-			assigned = true
+/*
+AddReviewer назначает на PR указанного пользователя в дополнение
+к текущим ревьюверам. Пользователь должен быть активен, доступен,
+иметь запас max_open_reviews, не быть автором PR и ещё не быть назначенным.
+Число ревьюверов не может превысить max_reviewers команды автора.
+
+Эндпоинт: POST /pullRequest/addReviewer.
+*/
+func (s *Service) AddReviewer(ctx context.Context, prID, uid string) (*model.PullRequest, error) {
+	var pr *model.PullRequest
+	err := s.repo.InTx(ctx, func(ctx context.Context) error {
+		cur, err := s.lockPR(ctx, prID)
+		if err != nil {
+			return err
+		}
+		if err := requireOpen(cur); err != nil {
+			return err
 		}
+		if _, err := s.checkNewReviewer(ctx, cur, uid); err != nil {
+			return err
+		}
+		_, ts, err := s.authorSettings(ctx, cur.AuthorID)
+		if err != nil {
+			return err
+		}
+		if ts.MaxReviewers > 0 && len(cur.AssignedReviewers) >= ts.MaxReviewers {
+			return ErrMaxReviewers
+		}
+
+		revs := append(cur.AssignedReviewers, uid)
+		if err := s.repo.SetPRReviewers(ctx, prID, revs); err != nil {
+			return err
+		}
+		if err := s.repo.SetReviewerOrigin(ctx, prID, uid, model.ReviewerOrigin{MatchReason: model.MatchManual}); err != nil {
+			return err
+		}
+
+		pr, err = s.repo.GetPullRequestWithReviewers(ctx, prID)
+		return err
+	})
+	if err != nil {
+		return nil, err
 	}
-	if !assigned {
-		return nil, "", ErrNotAssigned
+	return pr, nil
+}
+
+/*
+RemoveReviewer снимает пользователя с ревью PR без замены.
+Число ревьюверов не может стать меньше min_reviewers команды автора,
+и нельзя снять единственного старшего, если команда его требует.
+
+Эндпоинт: POST /pullRequest/removeReviewer.
+*/
+func (s *Service) RemoveReviewer(ctx context.Context, prID, uid string) (*model.PullRequest, error) {
+	var pr *model.PullRequest
+	err := s.repo.InTx(ctx, func(ctx context.Context) error {
+		cur, err := s.lockPR(ctx, prID)
+		if err != nil {
+			return err
+		}
+		if err := requireOpen(cur); err != nil {
+			return err
+		}
+
+		revs := []string{}
+		for _, r := range cur.AssignedReviewers {
+			if r != uid {
+				revs = append(revs, r)
+			}
+		}
+		if len(revs) == len(cur.AssignedReviewers) {
+			return ErrNotAssigned
+		}
+		_, ts, err := s.authorSettings(ctx, cur.AuthorID)
+		if err != nil {
+			return err
+		}
+		if len(revs) < ts.MinReviewers {
+			return ErrMinReviewers
+		}
+		if ts.RequireSenior {
+			had, err := s.hasSenior(ctx, cur.AssignedReviewers)
+			if err != nil {
+				return err
+			}
+			has, err := s.hasSenior(ctx, revs)
+			if err != nil {
+				return err
+			}
+			if had && !has {
+				return ErrSeniorRequired
+			}
+		}
+
+		if err := s.repo.SetPRReviewers(ctx, prID, revs); err != nil {
+			return err
+		}
+
+		pr, err = s.repo.GetPullRequestWithReviewers(ctx, prID)
+		return err
+	})
+	if err != nil {
+		return nil, err
 	}
+	return pr, nil
+}
 
-	oldUser, err := s.repo.GetUserByID(ctx, old)
+/*
+checkNewReviewer проверяет, что пользователя можно назначить ревьювером PR:
+он существует, активен, не является автором и ещё не назначен, сейчас
+не в окне недоступности (ErrUserUnavailable) и не исчерпал max_open_reviews
+(ErrAtCapacity).
+*/
+func (s *Service) checkNewReviewer(ctx context.Context, pr *model.PullRequest, uid string) (*model.User, error) {
+	user, err := s.repo.GetUserByID(ctx, uid)
 	if err != nil {
-		return nil, "", ErrNotFound
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
 	}
 
-	exclude := append([]string{old, pr.AuthorID}, pr.AssignedReviewers...)
+	if !user.IsActive {
+		return nil, ErrUserInactive
+	}
+	if uid == pr.AuthorID {
+		return nil, ErrIsAuthor
+	}
+	for _, r := range pr.AssignedReviewers {
+		if r == uid {
+			return nil, ErrAlreadyAssigned
+		}
+	}
 
-	candidates, err := s.repo.GetRandomActiveReviewersFromTeamExcluding(
-		ctx,
-		oldUser.TeamName,
-		1,
-		exclude,
-	)
+	// Кандидаты команды — активные участники вне окон недоступности.
+	candidates, err := s.repo.GetReviewCandidates(ctx, user.TeamName, nil)
 	if err != nil {
-		return nil, "", err
+		return nil, err
 	}
-
-	if len(candidates) == 0 {
-		return nil, "", ErrNoCandidate
+	for _, c := range candidates {
+		if c.UserID != uid {
+			continue
+		}
+		if !c.HasCapacity() {
+			return nil, ErrAtCapacity
+		}
+		return user, nil
 	}
+	return nil, ErrUserUnavailable
+}
+
+/*
+SubmitReview сохраняет решение ревьювера по PR: APPROVED, CHANGES_REQUESTED
+или COMMENTED. Повторная отправка заменяет предыдущее решение.
 
-	newReviewer := candidates[0]
+Эндпоинт: POST /pullRequest/review.
+*/
+func (s *Service) SubmitReview(ctx context.Context, prID, uid string, state model.ReviewState) (*model.PullRequest, error) {
+	if !state.IsDecision() {
+		return nil, ErrInvalid
+	}
 
-	for i := range pr.AssignedReviewers {
-		if pr.AssignedReviewers[i] == old {
-			pr.AssignedReviewers[i] = newReviewer
+	pr, err := s.repo.GetPullRequestWithReviewers(ctx, prID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
 		}
+		return nil, err
 	}
 
-	err = s.repo.SetPRReviewers(ctx, pr.ID, pr.AssignedReviewers)
+	if err := requireOpen(pr); err != nil {
+		return nil, err
+	}
+
+	err = s.repo.SetReviewState(ctx, prID, uid, state, time.Now().UTC())
 	if err != nil {
-		return nil, "", err
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotAssigned
+		}
+		return nil, err
 	}
 
-	return pr, newReviewer, nil
+	return s.repo.GetPullRequestWithReviewers(ctx, prID)
 }
 
 /*
@@ -0,0 +1,227 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"pr-review-service/internal/model"
+)
+
+/*
+FillReviewers доназначает ревьюверов открытому PR до reviewer_count
+команды автора, не трогая уже назначенных. Возвращает PR и добавленных
+ревьюверов; если кандидатов по-прежнему не хватает, PR помечается understaffed.
+
+Эндпоинт: POST /pullRequest/fillReviewers.
+*/
+func (s *Service) FillReviewers(ctx context.Context, id string) (*model.PullRequest, []string, error) {
+	var (
+		pr    *model.PullRequest
+		added []string
+	)
+	err := s.repo.InTx(ctx, func(ctx context.Context) error {
+		cur, err := s.lockPR(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := requireOpen(cur); err != nil {
+			return err
+		}
+
+		var want int
+		added, want, err = s.fillReviewers(ctx, cur)
+		if err != nil {
+			return err
+		}
+
+		pr, err = s.repo.GetPullRequestWithReviewers(ctx, id)
+		if err != nil {
+			return err
+		}
+		pr.Understaffed = len(pr.AssignedReviewers) < want
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return pr, added, nil
+}
+
+/*
+fillReviewers выбирает недостающих до reviewer_count ревьюверов из команды
+автора (предпочитая совпавших по тегам) и её резервных команд и добавляет
+их к текущим. Если команда требует старшего, а его на PR нет, первым
+добирается старший, когда он доступен. Выбор, который что-то добавил,
+сохраняется как назначение FILL. Возвращает добавленных и желаемое
+число ревьюверов.
+*/
+func (s *Service) fillReviewers(ctx context.Context, pr *model.PullRequest) ([]string, int, error) {
+	author, ts, err := s.authorSettings(ctx, pr.AuthorID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	missing := ts.ReviewerCount - len(pr.AssignedReviewers)
+	if missing <= 0 {
+		return []string{}, ts.ReviewerCount, nil
+	}
+
+	exclude := append([]string{pr.AuthorID}, pr.AssignedReviewers...)
+	ctx, tr := s.startAssignment(ctx, exclude)
+	added := []string{}
+	origins := map[string]model.ReviewerOrigin{}
+
+	need, err := s.needSenior(ctx, ts, pr.AssignedReviewers)
+	if err != nil {
+		return nil, 0, err
+	}
+	if need {
+		senior, o, err := s.selectSenior(ctx, author.TeamName, pr.Labels, exclude)
+		if err != nil {
+			return nil, 0, err
+		}
+		if senior != "" {
+			added = append(added, senior)
+			origins[senior] = o
+			exclude = append(exclude, senior)
+		}
+	}
+
+	rest, restOrigins, err := s.selectForPR(ctx, pr.AuthorID, author.TeamName, pr.Labels, missing-len(added), exclude)
+	if err != nil && !(errors.Is(err, ErrAtCapacity) && len(added) > 0) {
+		return nil, 0, err
+	}
+	added = append(added, rest...)
+	for uid, o := range restOrigins {
+		origins[uid] = o
+	}
+	if len(added) == 0 {
+		return added, ts.ReviewerCount, nil
+	}
+
+	revs := append(append([]string{}, pr.AssignedReviewers...), added...)
+	if err := s.repo.SetPRReviewers(ctx, pr.ID, revs); err != nil {
+		return nil, 0, err
+	}
+	if err := s.saveOrigins(ctx, pr.ID, origins); err != nil {
+		return nil, 0, err
+	}
+	if err := s.saveAssignment(ctx, pr.ID, model.AssignmentFill, tr, added); err != nil {
+		return nil, 0, err
+	}
+	return added, ts.ReviewerCount, nil
+}
+
+// assignment — ревьюверы, выбранные для PR, и откуда они взяты.
+type assignment struct {
+	reviewers []string
+	origins   map[string]model.ReviewerOrigin
+
+	// want — желаемое число ревьюверов (reviewer_count команды автора).
+	want int
+
+	// trace — шаги выбора для записи о нём (nil, если выбора не было).
+	trace *assignmentTrace
+}
+
+/*
+selectWithFallback выбирает до limit ревьюверов стратегией команды team,
+а если их не хватает — добирает из резервных команд team по порядку.
+Для каждого выбранного возвращается происхождение (TEAM или FALLBACK_TEAM).
+ErrAtCapacity возвращается, только если не выбран никто и хотя бы
+в одной из команд все кандидаты исчерпали лимит.
+*/
+func (s *Service) selectWithFallback(ctx context.Context, team string, limit int, exclude []string) ([]string, map[string]model.ReviewerOrigin, error) {
+	ts, err := s.repo.GetTeamSettings(ctx, team)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	chosen := []string{}
+	origins := map[string]model.ReviewerOrigin{}
+	atCapacity := false
+	for i, t := range append([]string{team}, ts.FallbackTeams...) {
+		if len(chosen) >= limit {
+			break
+		}
+
+		skip := append(append([]string{}, exclude...), chosen...)
+		reason := model.MatchTeam
+		if i > 0 {
+			reason = model.MatchFallbackTeam
+		}
+		revs, err := s.selectReviewers(ctx, t, reason, limit-len(chosen), skip)
+		if errors.Is(err, ErrAtCapacity) {
+			atCapacity = true
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, r := range revs {
+			chosen = append(chosen, r)
+			o := model.ReviewerOrigin{MatchReason: reason}
+			if i > 0 {
+				o.FallbackTeam = t
+			}
+			origins[r] = o
+		}
+	}
+
+	if len(chosen) == 0 && atCapacity {
+		return nil, nil, ErrAtCapacity
+	}
+	return chosen, origins, nil
+}
+
+// saveOrigins сохраняет происхождение назначенных ревьюверов PR.
+func (s *Service) saveOrigins(ctx context.Context, prID string, origins map[string]model.ReviewerOrigin) error {
+	for uid, o := range origins {
+		if err := s.repo.SetReviewerOrigin(ctx, prID, uid, o); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+topUpTeam доназначает ревьюверов всем открытым PR авторов команды,
+у которых их меньше reviewer_count. Вызывается, когда в команде появляется
+доступный участник. PR, для которых все кандидаты исчерпали лимит,
+пропускаются.
+*/
+func (s *Service) topUpTeam(ctx context.Context, team string) ([]model.ReviewerFill, error) {
+	ts, err := s.repo.GetTeamSettings(ctx, team)
+	if err != nil {
+		return nil, err
+	}
+
+	ids, err := s.repo.GetUnderstaffedPullRequests(ctx, team, ts.ReviewerCount)
+	if err != nil {
+		return nil, err
+	}
+
+	fills := []model.ReviewerFill{}
+	for _, id := range ids {
+		pr, err := s.lockPR(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if pr.Status != model.PRStatusOpen {
+			continue
+		}
+
+		added, _, err := s.fillReviewers(ctx, pr)
+		if errors.Is(err, ErrAtCapacity) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(added) > 0 {
+			fills = append(fills, model.ReviewerFill{PullRequestID: id, Added: added})
+		}
+	}
+	return fills, nil
+}
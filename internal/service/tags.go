@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+
+	"pr-review-service/internal/model"
+)
+
+/*
+normalizeTags приводит теги пользователя или метки PR к каноничному виду:
+нижний регистр, без повторов, по алфавиту. Пустой тег или тег с пробелами
+недопустим.
+*/
+func normalizeTags(tags []string) ([]string, error) {
+	seen := map[string]bool{}
+	result := []string{}
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || strings.ContainsAny(tag, " \t\r\n") {
+			return nil, ErrInvalid
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			result = append(result, tag)
+		}
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+// matchTags возвращает теги, которые есть и в tags, и в labels.
+func matchTags(tags, labels []string) []string {
+	want := map[string]bool{}
+	for _, l := range labels {
+		want[l] = true
+	}
+
+	var matched []string
+	for _, t := range tags {
+		if want[t] {
+			matched = append(matched, t)
+		}
+	}
+	return matched
+}
+
+/*
+selectForPR выбирает до limit ревьюверов PR автора author с метками labels:
+сначала участников team, чьи теги пересекаются с метками (selectByTags),
+затем недостающих — стратегией team и из её резервных команд
+(selectWithFallback). Автор передаётся стратегиям через WithPRAuthor.
+*/
+func (s *Service) selectForPR(ctx context.Context, author, team string, labels []string, limit int, exclude []string) ([]string, map[string]model.ReviewerOrigin, error) {
+	ctx = WithPRAuthor(ctx, author)
+	chosen, origins, err := s.selectByTags(ctx, team, labels, limit, exclude)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	skip := append(append([]string{}, exclude...), chosen...)
+	rest, restOrigins, err := s.selectWithFallback(ctx, team, limit-len(chosen), skip)
+	if err != nil && !(errors.Is(err, ErrAtCapacity) && len(chosen) > 0) {
+		return nil, nil, err
+	}
+
+	chosen = append(chosen, rest...)
+	for uid, o := range restOrigins {
+		origins[uid] = o
+	}
+	return chosen, origins, nil
+}
+
+/*
+selectByTags выбирает до limit участников team с доступной ёмкостью, чьи
+теги пересекаются с метками PR. Предпочтение — большему числу совпавших
+тегов, затем меньшей нагрузке, затем user_id. Если у PR есть метки,
+записывается шаг выбора TAG_MATCH.
+*/
+func (s *Service) selectByTags(ctx context.Context, team string, labels []string, limit int, exclude []string) ([]string, map[string]model.ReviewerOrigin, error) {
+	chosen := []string{}
+	origins := map[string]model.ReviewerOrigin{}
+	if len(labels) == 0 || limit <= 0 {
+		return chosen, origins, nil
+	}
+
+	candidates, err := s.repo.GetReviewCandidates(ctx, team, exclude)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	type match struct {
+		c       model.ReviewCandidate
+		matched []string
+	}
+	matches := []match{}
+	matching := []model.ReviewCandidate{}
+	for _, c := range candidates {
+		m := matchTags(c.Tags, labels)
+		if len(m) == 0 {
+			continue
+		}
+		matching = append(matching, c)
+		if c.HasCapacity() {
+			matches = append(matches, match{c: c, matched: m})
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if len(a.matched) != len(b.matched) {
+			return len(a.matched) > len(b.matched)
+		}
+		if a.c.OpenReviews != b.c.OpenReviews {
+			return a.c.OpenReviews < b.c.OpenReviews
+		}
+		return a.c.UserID < b.c.UserID
+	})
+
+	for _, m := range matches {
+		if len(chosen) >= limit {
+			break
+		}
+		chosen = append(chosen, m.c.UserID)
+		origins[m.c.UserID] = model.ReviewerOrigin{MatchReason: model.MatchTag, MatchedTags: m.matched}
+	}
+
+	step := candidateStep(model.MatchTag, team, matching)
+	step.Chosen = append(step.Chosen, chosen...)
+	recordStep(ctx, step)
+	return chosen, origins, nil
+}